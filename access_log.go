@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// NewAccessLog returns a handler that writes an access log line for each
+// request to w, or to os.Stderr when w is nil.
+func NewAccessLog(w io.Writer) *AccessLog {
+	if w == nil {
+		w = os.Stderr
+	}
+
+	return &AccessLog{Writer: w}
+}
+
+// AccessLog is the handler responsible for access logs in the Common Log
+// Format used by Apache and most log tooling:
+//
+//	host ident authuser [date] "request line" status bytes
+type AccessLog struct {
+	Writer io.Writer
+	// Combined logs the Combined Log Format, which appends the quoted Referer
+	// and User-Agent.
+	Combined bool
+	// RequestID appends the quoted request ID from the context, or "-".
+	RequestID bool
+	// TrustedProxyHeaders lists headers, such as X-Forwarded-For, trusted to
+	// carry the remote host. Set it only behind a proxy that overwrites them,
+	// since clients can otherwise forge the logged host. Of a list such as
+	// X-Forwarded-For, the entry appended by the nearest proxy is used. By
+	// default only the remote address is used.
+	TrustedProxyHeaders []string
+
+	mu sync.Mutex
+}
+
+// Handler implements the middleware interface.
+func (h *AccessLog) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		h.write(h.line(r, rw, start))
+	})
+}
+
+func (h *AccessLog) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *AccessLog) line(r *http.Request, rw *responseWriter, start time.Time) string {
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	size := "-"
+	if n := rw.written(); n > 0 {
+		size = strconv.FormatInt(n, 10)
+	}
+
+	uri := r.RequestURI
+	if len(uri) == 0 {
+		uri = r.URL.RequestURI()
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s - %s [%s] \"%s %s %s\" %d %s",
+		clfField(clientIP(r, h.TrustedProxyHeaders, 1)),
+		clfField(clfUser(r)),
+		start.Format(clfTimeLayout),
+		clfEscape(r.Method), clfEscape(uri), clfEscape(r.Proto),
+		status, size)
+
+	if h.Combined {
+		fmt.Fprintf(&b, " \"%s\" \"%s\"", clfEscape(r.Referer()), clfEscape(r.UserAgent()))
+	}
+
+	if h.RequestID {
+		id, _ := GetRequestID(r.Context())
+		fmt.Fprintf(&b, " \"%s\"", clfEscape(id))
+	}
+
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+func (h *AccessLog) write(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	io.WriteString(h.Writer, line) // nolint:errcheck
+}
+
+// clfUser returns the authenticated user of r, from the URL or HTTP Basic
+// credentials.
+func clfUser(r *http.Request) string {
+	if r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+
+	user, _, _ := r.BasicAuth()
+
+	return user
+}
+
+// clfField returns s for an unquoted field, or "-" when it is empty.
+func clfField(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return strings.Map(func(c rune) rune {
+		if c <= ' ' || c == '"' || c == 0x7f {
+			return '_'
+		}
+
+		return c
+	}, s)
+}
+
+// clfEscape escapes s for a quoted field, or returns "-" when it is empty.
+func clfEscape(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	q := strconv.Quote(s)
+
+	return q[1 : len(q)-1]
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var clfLine = regexp.MustCompile(
+	`^(\S+) (\S+) (\S+) \[([^\]]+)\] "(\S+) (\S+) (\S+)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?(?: "([^"]*)")?\n$`)
+
+func accessLogLine(t *testing.T, h *AccessLog, handler http.HandlerFunc, r *http.Request) []string {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	h.Writer = &out
+	h.Handler(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	m := clfLine.FindStringSubmatch(out.String())
+	if m == nil {
+		t.Fatalf("unparsable access log line %q", out.String())
+	}
+
+	return m
+}
+
+func TestAccessLogCommon(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/things?id=1", nil)
+	r.RemoteAddr = "203.0.113.7:4321"
+	r.SetBasicAuth("alice", "secret")
+
+	m := accessLogLine(t, NewAccessLog(nil), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created")) // nolint:errcheck
+	}, r)
+
+	host, ident, user, date, method, path, proto, status, size := m[1], m[2], m[3], m[4], m[5], m[6], m[7], m[8], m[9]
+
+	if host != "203.0.113.7" || ident != "-" || user != "alice" {
+		t.Errorf("unexpected host, ident or user: %q %q %q", host, ident, user)
+	}
+
+	if _, err := time.Parse(clfTimeLayout, date); err != nil {
+		t.Errorf("unexpected date %q: %v", date, err)
+	}
+
+	if method != http.MethodPost || path != "/things?id=1" || proto != "HTTP/1.1" {
+		t.Errorf("unexpected request line: %q %q %q", method, path, proto)
+	}
+
+	if status != "201" || size != "7" {
+		t.Errorf("unexpected status or size: %q %q", status, size)
+	}
+
+	if len(m[10]) > 0 || len(m[12]) > 0 {
+		t.Errorf("expected no combined or request ID fields, got %q", m[0])
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", `agent "quoted"`)
+	r = r.WithContext(WithRequestID(r.Context(), "req-1"))
+
+	h := &AccessLog{Combined: true, RequestID: true}
+
+	m := accessLogLine(t, h, func(w http.ResponseWriter, r *http.Request) {}, r)
+
+	if status, size := m[8], m[9]; status != "200" || size != "-" {
+		t.Errorf("expected an empty 200, got %q %q", status, size)
+	}
+
+	if referer, agent, id := m[10], m[11], m[12]; referer != "https://example.com/" ||
+		agent != `agent \"quoted\"` || id != "req-1" {
+		t.Errorf("unexpected referer, user agent or request ID: %q %q %q", referer, agent, id)
+	}
+}
+
+func TestAccessLogRemoteHost(t *testing.T) {
+	cases := []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{name: "forged header ignored", expected: "10.0.0.1"},
+		{name: "trusted proxy", trusted: []string{"X-Forwarded-For"}, expected: "203.0.113.7"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:4321"
+			r.Header.Set("X-Forwarded-For", "198.51.100.66, 203.0.113.7")
+
+			m := accessLogLine(t, &AccessLog{TrustedProxyHeaders: c.trusted}, func(http.ResponseWriter, *http.Request) {}, r)
+			if m[1] != c.expected {
+				t.Errorf("expected host %q, got %q", c.expected, m[1])
+			}
+		})
+	}
+}
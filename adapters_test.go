@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// negroniHandlerFunc mirrors the handler func shape of negroni.
+type negroniHandlerFunc func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+
+// negroniServe dispatches like negroni: each handler calls the next through
+// its next argument, and the last one is followed by h.
+func negroniServe(handlers []negroniHandlerFunc, h http.Handler, w http.ResponseWriter, r *http.Request) {
+	if len(handlers) == 0 {
+		h.ServeHTTP(w, r)
+
+		return
+	}
+
+	handlers[0](w, r, func(w http.ResponseWriter, r *http.Request) {
+		negroniServe(handlers[1:], h, w, r)
+	})
+}
+
+// aliceThen applies constructors like alice.New(c...).Then(h).
+func aliceThen(h http.Handler, c ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+
+	return h
+}
+
+func TestNegroni(t *testing.T) {
+	var seq []string
+
+	handlers := []negroniHandlerFunc{
+		Negroni(traceMiddleware{"a", &seq}),
+		Negroni(traceMiddleware{"b", &seq}),
+		Negroni(traceMiddleware{"c", &seq}),
+	}
+
+	negroniServe(handlers, seqHandler(&seq), httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !reflect.DeepEqual(seq, expectedChainSeq) {
+		t.Errorf("expected %v, got %v", expectedChainSeq, seq)
+	}
+}
+
+func TestNegroniRequestContext(t *testing.T) {
+	var id string
+
+	handlers := []negroniHandlerFunc{Negroni(NewRequestIDHandler(func() string { return "abc" }))}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+	})
+
+	negroniServe(handlers, h, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if id != "abc" {
+		t.Errorf("expected the request ID passed on to next, got %q", id)
+	}
+}
+
+func TestAlice(t *testing.T) {
+	var seq []string
+
+	h := aliceThen(seqHandler(&seq),
+		Alice(traceMiddleware{"a", &seq}),
+		Alice(traceMiddleware{"b", &seq}),
+		Alice(traceMiddleware{"c", &seq}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !reflect.DeepEqual(seq, expectedChainSeq) {
+		t.Errorf("expected %v, got %v", expectedChainSeq, seq)
+	}
+}
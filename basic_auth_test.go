@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	h := NewBasicAuth("ops", StaticCredentials("admin", "s3cr3t")).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("welcome")) })) // nolint:errcheck
+
+	cases := []struct {
+		name     string
+		user     string
+		pass     string
+		noAuth   bool
+		expected int
+	}{
+		{name: "missing", noAuth: true, expected: http.StatusUnauthorized},
+		{name: "wrong password", user: "admin", pass: "guess", expected: http.StatusUnauthorized},
+		{name: "wrong user", user: "root", pass: "s3cr3t", expected: http.StatusUnauthorized},
+		{name: "correct", user: "admin", pass: "s3cr3t", expected: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if !c.noAuth {
+				r.SetBasicAuth(c.user, c.pass)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.expected {
+				t.Errorf("expected %d, got %d", c.expected, w.Code)
+			}
+
+			challenge := w.Header().Get("WWW-Authenticate")
+			if c.expected == http.StatusUnauthorized && challenge != `Basic realm="ops", charset="UTF-8"` {
+				t.Errorf("unexpected challenge %q", challenge)
+			}
+
+			if c.expected == http.StatusOK && (len(challenge) > 0 || w.Body.String() != "welcome") {
+				t.Errorf("expected the handler's response, got %q %q", challenge, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBasicAuthNoValidator(t *testing.T) {
+	h := (&BasicAuth{Realm: "ops"}).Handler(http.NotFoundHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("admin", "s3cr3t")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a validator, got %d", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import "net/http"
+
+// Middleware is implemented by the handler types of this package, such as
+// RequestIDHandler, RequestResponseLogger and Recoverer.
+type Middleware interface {
+	Handler(next http.Handler) http.Handler
+}
+
+// NextHandler is the ServeHTTP(w, r, next) shape also implemented by the
+// handler types of this package.
+type NextHandler interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler)
+}
+
+// Negroni adapts mw to the handler func shape of negroni, for use with
+// negroni.HandlerFunc.
+func Negroni(mw Middleware) func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		mw.Handler(next).ServeHTTP(w, r)
+	}
+}
+
+// Alice adapts mw to the constructor shape of alice, for use with alice.New.
+func Alice(mw Middleware) func(http.Handler) http.Handler {
+	return mw.Handler
+}
+
+// Chain composes mw into a single middleware that applies them left to right:
+// the first listed wraps outermost and sees each request first. The Handler
+// method of any Middleware can be passed directly, as in
+//
+//	Chain(ids.Handler, logger.Handler, recoverer.Handler)(mux)
+func Chain(mw ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			if mw[i] != nil {
+				next = mw[i](next)
+			}
+		}
+
+		return next
+	}
+}
+
+// ChainMiddleware is Chain for Middleware values.
+func ChainMiddleware(mw ...Middleware) func(http.Handler) http.Handler {
+	fns := make([]func(http.Handler) http.Handler, len(mw))
+	for i, m := range mw {
+		fns[i] = m.Handler
+	}
+
+	return Chain(fns...)
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// traceMiddleware records its name in seq before and after calling next.
+type traceMiddleware struct {
+	name string
+	seq  *[]string
+}
+
+func (m traceMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*m.seq = append(*m.seq, m.name+" in")
+		next.ServeHTTP(w, r)
+		*m.seq = append(*m.seq, m.name+" out")
+	})
+}
+
+func seqHandler(seq *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seq = append(*seq, "handler")
+	})
+}
+
+var expectedChainSeq = []string{"a in", "b in", "c in", "handler", "c out", "b out", "a out"}
+
+func TestChain(t *testing.T) {
+	var seq []string
+
+	a, b, c := traceMiddleware{"a", &seq}, traceMiddleware{"b", &seq}, traceMiddleware{"c", &seq}
+
+	h := Chain(a.Handler, nil, b.Handler, c.Handler)(seqHandler(&seq))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !reflect.DeepEqual(seq, expectedChainSeq) {
+		t.Errorf("expected %v, got %v", expectedChainSeq, seq)
+	}
+}
+
+func TestChainEmpty(t *testing.T) {
+	var seq []string
+
+	Chain()(seqHandler(&seq)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !reflect.DeepEqual(seq, []string{"handler"}) {
+		t.Errorf("expected only the handler, got %v", seq)
+	}
+}
+
+func TestChainMiddleware(t *testing.T) {
+	var seq []string
+
+	h := ChainMiddleware(traceMiddleware{"a", &seq}, traceMiddleware{"b", &seq}, traceMiddleware{"c", &seq})(
+		seqHandler(&seq))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !reflect.DeepEqual(seq, expectedChainSeq) {
+		t.Errorf("expected %v, got %v", expectedChainSeq, seq)
+	}
+}
+
+func TestChainPackageHandlers(t *testing.T) {
+	var id string
+
+	h := ChainMiddleware(
+		NewRequestIDHandler(func() string { return "req-1" }),
+		Logger(NoneLevel, &syncBuffer{}),
+		NewRecoverer(Logger(NoneLevel, &syncBuffer{})),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if id != "req-1" || w.Code != http.StatusInternalServerError {
+		t.Errorf("expected the request ID and a recovered panic, got %q and %d", id, w.Code)
+	}
+}
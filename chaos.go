@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ChaosConfig configures fault injection. Nothing is ever injected unless
+// Enabled is set.
+type ChaosConfig struct {
+	// Enabled must be explicitly set for any fault to be injected; when unset
+	// the middleware returns the next handler untouched.
+	Enabled bool
+	// Default applies to requests not matched by Routes.
+	Default ChaosRule
+	// Routes maps path prefixes to rules; the longest matching prefix wins.
+	Routes map[string]ChaosRule
+	Log    *log.Logger
+	// Rand returns a pseudo-random number in [0.0,1.0). Defaults to
+	// math/rand.Float64.
+	Rand func() float64
+}
+
+// ChaosRule describes the faults injected for a route.
+type ChaosRule struct {
+	// LatencyProbability is the chance of delaying a request by Latency.
+	LatencyProbability float64
+	Latency            time.Duration
+	// ErrorProbability is the chance of responding with ErrorStatus instead
+	// of calling the handler.
+	ErrorProbability float64
+	// ErrorStatus defaults to 500 Internal Server Error.
+	ErrorStatus int
+}
+
+// Chaos returns a handler that randomly injects latency and errors for
+// resilience testing.
+func Chaos(cfg ChaosConfig) *ChaosHandler {
+	if cfg.Log == nil {
+		cfg.Log = log.New(os.Stderr, " [chaos] ", log.LstdFlags)
+	}
+
+	if cfg.Rand == nil {
+		cfg.Rand = rand.Float64
+	}
+
+	return &ChaosHandler{cfg: cfg}
+}
+
+// ChaosHandler is the handler responsible for fault injection.
+type ChaosHandler struct {
+	cfg ChaosConfig
+}
+
+// Handler implements the middleware interface.
+func (h *ChaosHandler) Handler(next http.Handler) http.Handler {
+	if !h.cfg.Enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := h.rule(r.URL.Path)
+		id, _ := GetRequestID(r.Context())
+
+		if rule.Latency > 0 && h.cfg.Rand() < rule.LatencyProbability {
+			h.cfg.Log.Printf("injected latency %v request_id=%q method=%s path=%q", rule.Latency, id, r.Method, r.URL.Path)
+
+			select {
+			case <-time.After(rule.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if h.cfg.Rand() < rule.ErrorProbability {
+			status := rule.ErrorStatus
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+
+			h.cfg.Log.Printf("injected error %d request_id=%q method=%s path=%q", status, id, r.Method, r.URL.Path)
+			http.Error(w, http.StatusText(status), status)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ChaosHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *ChaosHandler) rule(path string) ChaosRule {
+	var (
+		rule    = h.cfg.Default
+		longest = -1
+	)
+
+	for prefix, r := range h.cfg.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			rule, longest = r, len(prefix)
+		}
+	}
+
+	return rule
+}
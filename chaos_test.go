@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func chaosServe(cfg ChaosConfig, path string) (*httptest.ResponseRecorder, bool) {
+	called := false
+
+	h := Chaos(cfg).Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r = r.WithContext(WithRequestID(r.Context(), "abc"))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	return rec, called
+}
+
+func TestChaosDisabledByDefault(t *testing.T) {
+	rolled := false
+
+	cfg := ChaosConfig{
+		Default: ChaosRule{ErrorProbability: 1, LatencyProbability: 1, Latency: time.Hour},
+		Rand:    func() float64 { rolled = true; return 0 },
+	}
+
+	rec, called := chaosServe(cfg, "/")
+
+	if rec.Code != http.StatusOK || !called || rolled {
+		t.Errorf("expected the handler untouched, got %d, called %t, rolled %t", rec.Code, called, rolled)
+	}
+}
+
+func TestChaosRoutes(t *testing.T) {
+	cfg := ChaosConfig{
+		Enabled: true,
+		Default: ChaosRule{ErrorProbability: 1, ErrorStatus: http.StatusBadGateway},
+		Routes: map[string]ChaosRule{
+			"/api/":        {ErrorProbability: 1, ErrorStatus: http.StatusServiceUnavailable},
+			"/api/health":  {},
+			"/api/healthy": {ErrorProbability: 1},
+		},
+		Log:  log.New(&bytes.Buffer{}, "", 0),
+		Rand: func() float64 { return 0.5 },
+	}
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{path: "/", status: http.StatusBadGateway},
+		{path: "/api/things", status: http.StatusServiceUnavailable},
+		{path: "/api/health", status: http.StatusOK},
+		{path: "/api/healthz", status: http.StatusOK},
+		{path: "/api/healthy", status: http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.path, func(t *testing.T) {
+			if rec, _ := chaosServe(cfg, c.path); rec.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestChaosProbability(t *testing.T) {
+	cfg := ChaosConfig{
+		Enabled: true,
+		Default: ChaosRule{ErrorProbability: 0.3},
+		Log:     log.New(&bytes.Buffer{}, "", 0),
+	}
+
+	for _, roll := range []float64{0.29, 0.3, 0.9} {
+		roll := roll
+		cfg.Rand = func() float64 { return roll }
+
+		if rec, _ := chaosServe(cfg, "/"); (rec.Code == http.StatusInternalServerError) != (roll < 0.3) {
+			t.Errorf("roll %v: unexpected status %d", roll, rec.Code)
+		}
+	}
+}
+
+func TestChaosLatencyLogged(t *testing.T) {
+	var logged bytes.Buffer
+
+	cfg := ChaosConfig{
+		Enabled: true,
+		Default: ChaosRule{LatencyProbability: 1, Latency: 20 * time.Millisecond},
+		Log:     log.New(&logged, "", 0),
+		Rand:    func() float64 { return 0 },
+	}
+
+	start := time.Now()
+	rec, called := chaosServe(cfg, "/slow")
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the injected latency, took %v", elapsed)
+	}
+
+	if rec.Code != http.StatusOK || !called {
+		t.Errorf("expected the handler called after the delay, got %d, %t", rec.Code, called)
+	}
+
+	if got := logged.String(); !strings.Contains(got, "injected latency 20ms") || !strings.Contains(got, `request_id="abc"`) {
+		t.Errorf("expected the fault logged with the request ID, got %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// TrafficClass distinguishes internal traffic, such as health probes and
+// monitoring, from external user traffic.
+type TrafficClass int
+
+// TrafficClass options.
+const (
+	ExternalTraffic TrafficClass = iota
+	InternalTraffic
+)
+
+const trafficClassKeyName contextKey = "traffic-class-key"
+
+// TrafficClassContextKey is the context key under which the traffic class is
+// stored.
+const TrafficClassContextKey = trafficClassKeyName
+
+func (c TrafficClass) String() string {
+	if c == InternalTraffic {
+		return "internal"
+	}
+
+	return "external"
+}
+
+// WithTrafficClass adds the traffic class into the context.
+func WithTrafficClass(ctx context.Context, class TrafficClass) context.Context {
+	return context.WithValue(ctx, trafficClassKeyName, class)
+}
+
+// GetTrafficClass returns the traffic class from the context and true if it exists.
+func GetTrafficClass(ctx context.Context) (TrafficClass, bool) {
+	class, ok := ctx.Value(trafficClassKeyName).(TrafficClass)
+
+	return class, ok
+}
+
+// NewTrafficClassifier returns a handler that tags requests from any of the
+// given networks, in CIDR notation, as internal and all others as external.
+func NewTrafficClassifier(cidrs ...string) (*TrafficClassifier, error) {
+	h := &TrafficClassifier{}
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		h.networks = append(h.networks, network)
+	}
+
+	return h, nil
+}
+
+// TrafficClassifier is the handler responsible for tagging requests with a
+// TrafficClass.
+type TrafficClassifier struct {
+	// Header, when set, marks requests carrying this header as internal.
+	Header string
+	// TrustedProxyHeaders lists headers, such as X-Forwarded-For, trusted to
+	// carry the client address. Of a list such as X-Forwarded-For, the entry
+	// appended by the nearest proxy is used. By default only the remote
+	// address is used.
+	TrustedProxyHeaders []string
+
+	networks []*net.IPNet
+}
+
+// Classify returns the traffic class of r.
+func (h *TrafficClassifier) Classify(r *http.Request) TrafficClass {
+	if len(h.Header) > 0 && len(r.Header.Get(h.Header)) > 0 {
+		return InternalTraffic
+	}
+
+	ip := net.ParseIP(clientIP(r, h.TrustedProxyHeaders, 1))
+	if ip == nil {
+		return ExternalTraffic
+	}
+
+	for _, network := range h.networks {
+		if network.Contains(ip) {
+			return InternalTraffic
+		}
+	}
+
+	return ExternalTraffic
+}
+
+// Handler implements the middleware interface.
+func (h *TrafficClassifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithTrafficClass(r.Context(), h.Classify(r))))
+	})
+}
+
+func (h *TrafficClassifier) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
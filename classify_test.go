@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrafficClassifier(t *testing.T) {
+	cases := []struct {
+		name     string
+		trusted  []string
+		remote   string
+		headers  map[string]string
+		expected TrafficClass
+	}{
+		{name: "internal remote", remote: "10.1.2.3:1234", expected: InternalTraffic},
+		{name: "external remote", remote: "203.0.113.9:1234", expected: ExternalTraffic},
+		{name: "unparsable remote", remote: "pipe", expected: ExternalTraffic},
+		{name: "probe header", remote: "203.0.113.9:1234", headers: map[string]string{"X-Probe": "1"}, expected: InternalTraffic},
+		{
+			name:     "spoofed forwarded for",
+			remote:   "203.0.113.9:1234",
+			headers:  map[string]string{"X-Forwarded-For": "10.1.2.3"},
+			expected: ExternalTraffic,
+		},
+		{
+			name:     "trusted forwarded for",
+			trusted:  []string{"X-Forwarded-For"},
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"X-Forwarded-For": "10.1.2.3, 203.0.113.9"},
+			expected: ExternalTraffic,
+		},
+		{
+			name:     "trusted internal forwarded for",
+			trusted:  []string{"X-Forwarded-For"},
+			remote:   "203.0.113.9:1234",
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7, 10.1.2.3"},
+			expected: InternalTraffic,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			h, err := NewTrafficClassifier("10.0.0.0/8", "fd00::/8")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h.Header = "X-Probe"
+			h.TrustedProxyHeaders = c.trusted
+
+			var (
+				got TrafficClass
+				ok  bool
+			)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = c.remote
+
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+
+			h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok = GetTrafficClass(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if !ok || got != c.expected {
+				t.Errorf("expected %v, got %v (%t)", c.expected, got, ok)
+			}
+		})
+	}
+}
+
+func TestTrafficClassifierInvalidCIDR(t *testing.T) {
+	if _, err := NewTrafficClassifier("10.0.0.0"); err == nil {
+		t.Error("expected an error for an invalid network")
+	}
+}
+
+func TestLoggerClassLevels(t *testing.T) {
+	var out bytes.Buffer
+
+	classifier, err := NewTrafficClassifier("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := Logger(MinimalLevel, &out, WithFormat(JSONFormat))
+	l.ClassLevels = map[TrafficClass]DetailLevel{InternalTraffic: NoneLevel}
+	h := classifier.Handler(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if out.Len() != 0 {
+		t.Errorf("expected internal traffic not logged, got %q", out.String())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.9:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := jsonEvent(t, out.String(), "response")["status"]; got != float64(http.StatusOK) {
+		t.Errorf("expected external traffic logged, got %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"log"
+	"net/http"
+	"os"
+)
+
+// RequireClientCert returns a handler that rejects requests without a TLS
+// client certificate, or whose certificate fails verify, with 403 Forbidden.
+// A nil verify accepts any presented certificate.
+func RequireClientCert(verify func(*x509.Certificate) error) *ClientCertHandler {
+	return &ClientCertHandler{verify: verify}
+}
+
+// ClientCertHandler is the handler responsible for enforcing and auditing TLS
+// client certificates.
+type ClientCertHandler struct {
+	Log *log.Logger
+
+	verify func(*x509.Certificate) error
+}
+
+// Handler implements the middleware interface.
+func (h *ClientCertHandler) Handler(next http.Handler) http.Handler {
+	logger := h.Log
+	if logger == nil {
+		logger = log.New(os.Stderr, " [client cert] ", log.LstdFlags)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := GetRequestID(r.Context())
+
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			logger.Printf("rejected request_id=%q method=%s path=%q: no client certificate", id, r.Method, r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+
+		cert := r.TLS.PeerCertificates[0]
+
+		if h.verify != nil {
+			if err := h.verify(cert); err != nil {
+				logger.Printf("rejected request_id=%q subject=%q serial=%s: %v", id, cert.Subject.String(), cert.SerialNumber, err)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+				return
+			}
+		}
+
+		logger.Printf("accepted request_id=%q subject=%q serial=%s", id, cert.Subject.String(), cert.SerialNumber)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ClientCertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "client.example"},
+		SerialNumber: big.NewInt(4242),
+	}
+
+	reject := func(*x509.Certificate) error { return errors.New("revoked") }
+
+	cases := []struct {
+		name   string
+		verify func(*x509.Certificate) error
+		state  *tls.ConnectionState
+		status int
+		logged []string
+	}{
+		{
+			name:   "no tls",
+			status: http.StatusForbidden,
+			logged: []string{"rejected", `request_id="abc"`, "no client certificate"},
+		},
+		{
+			name:   "no certificate",
+			state:  &tls.ConnectionState{},
+			status: http.StatusForbidden,
+			logged: []string{"rejected", "no client certificate"},
+		},
+		{
+			name:   "rejected",
+			verify: reject,
+			state:  &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			status: http.StatusForbidden,
+			logged: []string{"rejected", `subject="CN=client.example"`, "serial=4242", "revoked"},
+		},
+		{
+			name:   "accepted",
+			state:  &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			status: http.StatusOK,
+			logged: []string{"accepted", `request_id="abc"`, `subject="CN=client.example"`, "serial=4242"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var logged bytes.Buffer
+
+			called := false
+			h := RequireClientCert(c.verify)
+			h.Log = log.New(&logged, "", 0)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(WithRequestID(r.Context(), "abc"))
+			r.TLS = c.state
+
+			rec := httptest.NewRecorder()
+			h.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })).ServeHTTP(rec, r)
+
+			if rec.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, rec.Code)
+			}
+
+			if called != (c.status == http.StatusOK) {
+				t.Errorf("expected next called %t, got %t", c.status == http.StatusOK, called)
+			}
+
+			for _, want := range c.logged {
+				if !strings.Contains(logged.String(), want) {
+					t.Errorf("expected %q in %q", want, logged.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// nolint:gochecknoglobals
+var ansiColors = map[string]string{
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// color renders v, wrapped in the ANSI escapes for the named color when
+// colored output is enabled. Unknown colors, such as "", leave v plain.
+func (l *coreLogger) color(name string, v interface{}) string {
+	s := fmt.Sprint(v)
+
+	code, ok := ansiColors[name]
+	if !ok || !l.colored() {
+		return s
+	}
+
+	return code + s + ansiReset
+}
+
+func (l *coreLogger) colored() bool {
+	if !l.Color {
+		return false
+	}
+
+	l.settingsMu.RLock()
+	defer l.settingsMu.RUnlock()
+
+	return l.ForceColor || l.terminal
+}
+
+func statusGood(code int) bool { return http.StatusOK <= code && code < http.StatusBadRequest }
+
+func statusBad(code int) bool { return http.StatusBadRequest <= code }
+
+// statusColor returns the color for a status code: green when good, yellow for
+// client errors and red for server errors.
+func statusColor(code int) string {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return "red"
+	case statusBad(code):
+		return "yellow"
+	case statusGood(code):
+		return "green"
+	}
+
+	return ""
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+const defaultContentLengthMaxBytes = 1 << 20
+
+// NewContentLengthHandler returns a handler that verifies request bodies
+// against their declared Content-Length, reading at most maxBytes of each body,
+// and logs mismatches to logger.
+func NewContentLengthHandler(maxBytes int64, logger *RequestResponseLogger) *ContentLengthHandler {
+	if logger == nil {
+		logger = MinimalLogger(os.Stderr)
+	}
+
+	return &ContentLengthHandler{MaxBytes: maxBytes, logger: logger}
+}
+
+// ContentLengthHandler is the handler responsible for detecting requests whose
+// body does not match the declared Content-Length. A mismatch is logged as a
+// length_mismatch event giving the declared and actual sizes, and the size
+// limit, since the actual size of a body over MaxBytes is only known to
+// exceed it.
+type ContentLengthHandler struct {
+	// MaxBytes bounds how much of the body is read for verification. Bodies
+	// larger than this are only checked for exceeding the declared length.
+	MaxBytes int64
+	// Reject responds with 400 Bad Request on a mismatch instead of only
+	// logging it.
+	Reject bool
+
+	logger *RequestResponseLogger
+}
+
+// Handler implements the middleware interface.
+func (h *ContentLengthHandler) Handler(next http.Handler) http.Handler {
+	h.logger.initialize()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || r.ContentLength < 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		limit := h.MaxBytes
+		if limit <= 0 {
+			limit = defaultContentLengthMaxBytes
+		}
+
+		buf, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			h.logger.Log.Printf("Error reading body: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+			return
+		}
+
+		actual := int64(len(buf))
+		complete := err != nil || actual <= limit
+
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+
+		if (complete && actual != r.ContentLength) || actual > r.ContentLength {
+			id, _ := GetRequestID(r.Context())
+			h.logger.logEvent(&LogEvent{
+				Kind:         "length_mismatch",
+				RequestID:    id,
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				DeclaredSize: r.ContentLength,
+				ActualSize:   actual,
+				SizeLimit:    limit,
+			})
+
+			if h.Reject {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ContentLengthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+// replayBody re-joins the already consumed prefix of a body with the
+// remainder of the original, closing the original when done.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentLengthHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		declared int64
+		maxBytes int64
+		mismatch bool
+	}{
+		{name: "match", body: "hello", declared: 5},
+		{name: "short", body: "hello", declared: 10, mismatch: true},
+		{name: "long", body: "hello world", declared: 5, mismatch: true},
+		{name: "over max bytes within declared", body: "hello world", declared: 11, maxBytes: 4},
+		{name: "over max bytes and declared", body: "hello world", declared: 3, maxBytes: 4, mismatch: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			var got string
+
+			h := NewContentLengthHandler(c.maxBytes, Logger(MinimalLevel, &out, WithFormat(JSONFormat))).Handler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					b, _ := ioutil.ReadAll(r.Body)
+					got = string(b)
+				}))
+
+			r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(c.body))
+			r.ContentLength = c.declared
+			r = r.WithContext(WithRequestID(r.Context(), "abc"))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusOK || got != c.body {
+				t.Errorf("expected the whole body replayed to the handler, got %d %q", rec.Code, got)
+			}
+
+			if !c.mismatch {
+				if out.Len() > 0 {
+					t.Errorf("expected nothing logged, got %q", out.String())
+				}
+
+				return
+			}
+
+			ev := jsonEvent(t, out.String(), "length_mismatch")
+			if ev["request_id"] != "abc" || ev["method"] != http.MethodPost || ev["path"] != "/upload" {
+				t.Errorf("unexpected event %v", ev)
+			}
+
+			if ev["declared_size"] != float64(c.declared) {
+				t.Errorf("expected declared size %d, got %v", c.declared, ev["declared_size"])
+			}
+
+			actual := float64(len(c.body))
+			if c.maxBytes > 0 && actual > float64(c.maxBytes) {
+				actual = float64(c.maxBytes + 1)
+			}
+
+			if ev["actual_size"] != actual {
+				t.Errorf("expected actual size %v, got %v", actual, ev["actual_size"])
+			}
+		})
+	}
+}
+
+func TestContentLengthHandlerReject(t *testing.T) {
+	called := false
+
+	l := NewContentLengthHandler(0, Logger(MinimalLevel, ioutil.Discard))
+	l.Reject = true
+
+	h := l.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.ContentLength = 10
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusBadRequest || called {
+		t.Errorf("expected 400 without calling the handler, got %d, %t", rec.Code, called)
+	}
+}
+
+func TestContentLengthHandlerText(t *testing.T) {
+	var out bytes.Buffer
+
+	h := NewContentLengthHandler(0, Logger(MinimalLevel, &out)).Handler(http.HandlerFunc(
+		func(http.ResponseWriter, *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.ContentLength = 10
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := out.String(); !strings.Contains(got, "(length_mismatch)") || !strings.Contains(got, "declared_size=10") ||
+		!strings.Contains(got, "actual_size=5") {
+		t.Errorf("expected a structured text entry, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHasContentType(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		mimetypes   []string
+		expected    bool
+	}{
+		{name: "exact", contentType: "application/json", mimetypes: []string{"application/json"}, expected: true},
+		{name: "parameters", contentType: "text/html; charset=utf-8", mimetypes: []string{"text/html"}, expected: true},
+		{name: "case", contentType: "Application/JSON", mimetypes: []string{"application/json"}, expected: true},
+		{name: "one of", contentType: "text/plain", mimetypes: []string{"application/json", "text/plain"}, expected: true},
+		{name: "mismatch", contentType: "text/plain", mimetypes: []string{"text/html"}, expected: false},
+		{name: "subtype wildcard", contentType: "text/plain", mimetypes: []string{"text/*"}, expected: true},
+		{name: "subtype wildcard mismatch", contentType: "image/png", mimetypes: []string{"text/*"}, expected: false},
+		{name: "full wildcard", contentType: "image/png", mimetypes: []string{"*/*"}, expected: true},
+		{name: "missing", mimetypes: []string{"application/octet-stream"}, expected: true},
+		{name: "missing wildcard", mimetypes: []string{"application/*"}, expected: true},
+		{name: "missing mismatch", mimetypes: []string{"text/*"}, expected: false},
+		{name: "malformed", contentType: "/", mimetypes: []string{"*/*"}, expected: false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			h := http.Header{}
+			if len(c.contentType) > 0 {
+				h.Set("Content-Type", c.contentType)
+			}
+
+			if got := HasContentType(h, c.mimetypes...); got != c.expected {
+				t.Errorf("expected %t, got %t", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nolint:gochecknoglobals
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	defaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+)
+
+// NewCORS returns a handler that allows cross-origin requests from the given
+// origins.
+func NewCORS(origins ...string) *CORS {
+	return &CORS{AllowedOrigins: origins}
+}
+
+// CORS is the handler responsible for Cross-Origin Resource Sharing. It
+// answers preflight requests itself and adds the Access-Control-* headers to
+// the responses of allowed cross-origin requests.
+type CORS struct {
+	// AllowedOrigins lists the origins allowed to make requests. "*" allows
+	// any origin, and an entry may hold one "*" wildcard, as in
+	// "https://*.example.com".
+	AllowedOrigins []string
+	// ReflectOrigin echoes the request Origin back instead of answering "*"
+	// for origins allowed by a "*" entry.
+	ReflectOrigin bool
+	// AllowedMethods lists the methods allowed in preflight requests.
+	// Defaults to GET, HEAD and POST.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in preflight requests;
+	// "*" allows any. Defaults to Accept, Accept-Language, Content-Language and
+	// Content-Type.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers made available to scripts.
+	ExposedHeaders []string
+	// AllowCredentials allows requests carrying cookies or authorization.
+	// It requires explicit AllowedOrigins: Handler panics if any is "*", as
+	// that would open credentialed access to every site.
+	AllowCredentials bool
+	// MaxAge is how long preflight results may be cached. Zero leaves it to
+	// the browser.
+	MaxAge time.Duration
+}
+
+// Handler implements the middleware interface.
+func (h *CORS) Handler(next http.Handler) http.Handler {
+	if h.AllowCredentials && matchAny("*", h.AllowedOrigins...) {
+		panic(`middleware: CORS AllowCredentials cannot be used with the "*" origin`)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(origin) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) > 0 {
+			h.preflight(w, r, origin)
+
+			return
+		}
+
+		if allowed, ok := h.allowOrigin(origin); ok {
+			h.setOrigin(w, allowed)
+
+			if len(h.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(h.ExposedHeaders, ", "))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *CORS) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	allowed, ok := h.allowOrigin(origin)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	methods := h.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !matchAny(method, methods...) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	requested := requestedHeaders(r.Header)
+	for _, name := range requested {
+		if !h.allowHeader(name) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+	}
+
+	h.setOrigin(w, allowed)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(requested) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+
+	if h.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(h.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin and
+// whether it is allowed at all.
+func (h *CORS) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range h.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			if h.ReflectOrigin {
+				return origin, true
+			}
+
+			return "*", true
+		case matchOrigin(allowed, origin):
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+func (h *CORS) setOrigin(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+
+	if h.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (h *CORS) allowHeader(name string) bool {
+	allowed := h.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = defaultCORSHeaders
+	}
+
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOrigin reports whether origin matches pattern, compared
+// case-insensitively, where pattern may hold one "*" wildcard.
+func matchOrigin(pattern, origin string) bool {
+	pattern, origin = strings.ToLower(pattern), strings.ToLower(origin)
+
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return pattern == origin
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+
+	return len(origin) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// requestedHeaders returns the header names listed in the
+// Access-Control-Request-Headers of a preflight request.
+func requestedHeaders(h http.Header) []string {
+	var names []string
+
+	for _, v := range h.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
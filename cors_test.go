@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func corsRequest(h http.Handler, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	if len(origin) > 0 {
+		r.Header.Set("Origin", origin)
+	}
+
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func corsHandler(c *CORS) http.Handler {
+	return c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	c := NewCORS("https://app.example.com", "https://*.example.org")
+	c.ExposedHeaders = []string{"X-Request-ID"}
+	h := corsHandler(c)
+
+	for _, tc := range []struct {
+		origin, allowed string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://api.example.org", "https://api.example.org"},
+		{"https://evil.example.net", ""},
+	} {
+		w := corsRequest(h, http.MethodGet, tc.origin, nil)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.allowed {
+			t.Errorf("%s: expected allowed origin %q, got %q", tc.origin, tc.allowed, got)
+		}
+
+		if got := w.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("%s: expected Vary: Origin, got %q", tc.origin, got)
+		}
+
+		exposed := w.Header().Get("Access-Control-Expose-Headers")
+		if (len(tc.allowed) > 0) != (exposed == "X-Request-ID") {
+			t.Errorf("%s: unexpected exposed headers %q", tc.origin, exposed)
+		}
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	c := NewCORS("*")
+
+	w := corsRequest(corsHandler(c), http.MethodGet, "https://a.example.com", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+
+	c.ReflectOrigin = true
+
+	w = corsRequest(corsHandler(c), http.MethodGet, "https://a.example.com", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://a.example.com" {
+		t.Errorf("expected the reflected origin, got %q", got)
+	}
+}
+
+func TestCORSWithoutOrigin(t *testing.T) {
+	w := corsRequest(corsHandler(NewCORS("*")), http.MethodGet, "", nil)
+
+	if len(w.Header()) != 0 {
+		t.Errorf("expected no CORS headers, got %v", w.Header())
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	c := NewCORS("https://app.example.com")
+	c.AllowedMethods = []string{http.MethodGet, http.MethodPut}
+	c.AllowedHeaders = []string{"Content-Type", "X-Token"}
+	c.MaxAge = 10 * time.Minute
+	h := corsHandler(c)
+
+	w := corsRequest(h, http.MethodOptions, "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  http.MethodPut,
+		"Access-Control-Request-Headers": "content-type, x-token",
+	})
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+
+	for k, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, PUT",
+		"Access-Control-Allow-Headers": "Content-Type, X-Token",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := w.Header().Get(k); got != want {
+			t.Errorf("%s: expected %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestCORSPreflightRejected(t *testing.T) {
+	c := NewCORS("https://app.example.com")
+	h := corsHandler(c)
+
+	for name, tc := range map[string]struct {
+		origin string
+		header map[string]string
+	}{
+		"origin": {"https://evil.example.com", map[string]string{"Access-Control-Request-Method": http.MethodGet}},
+		"method": {"https://app.example.com", map[string]string{"Access-Control-Request-Method": http.MethodDelete}},
+		"header": {"https://app.example.com", map[string]string{
+			"Access-Control-Request-Method":  http.MethodGet,
+			"Access-Control-Request-Headers": "X-Secret",
+		}},
+	} {
+		w := corsRequest(h, http.MethodOptions, tc.origin, tc.header)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, w.Code)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); len(got) > 0 {
+			t.Errorf("%s: unexpected allowed origin %q", name, got)
+		}
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	c := NewCORS("https://app.example.com")
+	c.AllowCredentials = true
+
+	w := corsRequest(corsHandler(c), http.MethodGet, "https://app.example.com", nil)
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("expected credentials allowed, got %q", got)
+	}
+}
+
+func TestCORSCredentialsWithAnyOrigin(t *testing.T) {
+	c := NewCORS("*")
+	c.AllowCredentials = true
+
+	defer func() {
+		if recover() == nil {
+			t.Error(`expected a panic for AllowCredentials with the "*" origin`)
+		}
+	}()
+
+	corsHandler(c)
+}
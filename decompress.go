@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDecompressLimit = 10 << 20
+	// decompressDrainFactor bounds, as a multiple of the limit, how much of a
+	// body over the limit is decompressed to report its size.
+	decompressDrainFactor = 4
+)
+
+// NewDecompressor returns a handler that decompresses gzip and deflate encoded
+// request bodies, rejecting bodies that decompress to more than limit bytes.
+func NewDecompressor(limit int64, logger *RequestResponseLogger) *Decompressor {
+	if logger == nil {
+		logger = MinimalLogger(os.Stderr)
+	}
+
+	return &Decompressor{Limit: limit, logger: logger}
+}
+
+// Decompressor is the handler responsible for decoding compressed request
+// bodies before they reach the handler. A body over the limit is rejected with
+// 413 Request Entity Too Large and logged as a decompression_limit_exceeded
+// event, whose actual size is counted up to four times the limit.
+type Decompressor struct {
+	// Limit is the maximum decompressed body size. Defaults to 10MiB.
+	Limit int64
+
+	logger *RequestResponseLogger
+}
+
+// Handler implements the middleware interface.
+func (h *Decompressor) Handler(next http.Handler) http.Handler {
+	h.logger.initialize()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		zr, ok, err := decodingReader(r.Header.Get("Content-Encoding"), r.Body)
+		if !ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+			return
+		}
+
+		limit := h.Limit
+		if limit <= 0 {
+			limit = defaultDecompressLimit
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(zr, limit+1))
+		if err != nil {
+			zr.Close()     // nolint:errcheck
+			r.Body.Close() // nolint:errcheck
+			h.logger.Log.Printf("Error decompressing body: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+			return
+		}
+
+		if int64(len(body)) > limit {
+			// Count on, up to the bound, to log how far over the limit the
+			// body went without decompressing all of a zip bomb.
+			rest, _ := io.Copy(ioutil.Discard, io.LimitReader(zr, decompressDrainFactor*limit-int64(len(body))))
+			zr.Close()     // nolint:errcheck
+			r.Body.Close() // nolint:errcheck
+
+			id, _ := GetRequestID(r.Context())
+			h.logger.logEvent(&LogEvent{
+				Kind:           "decompression_limit_exceeded",
+				RequestID:      id,
+				Method:         r.Method,
+				Path:           r.URL.Path,
+				CompressedSize: r.ContentLength,
+				ActualSize:     int64(len(body)) + rest,
+				SizeLimit:      limit,
+			})
+
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		zr.Close()     // nolint:errcheck
+		r.Body.Close() // nolint:errcheck
+
+		r.Header.Del("Content-Encoding")
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		r.ContentLength = int64(len(body))
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Decompressor) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+// decodingReader returns a reader decompressing r according to the given
+// Content-Encoding, reporting false when the encoding is not gzip or deflate.
+func decodingReader(encoding string, r io.Reader) (io.ReadCloser, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+
+		return zr, true, err
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+
+		return zr, true, err
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func compressed(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	var zw io.WriteCloser
+	if encoding == "gzip" {
+		zw = gzip.NewWriter(&buf)
+	} else {
+		zw = zlib.NewWriter(&buf)
+	}
+
+	if _, err := zw.Write(body); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func decompressRequest(h *Decompressor, encoding string, body []byte) (*httptest.ResponseRecorder, string, *http.Request) {
+	var (
+		got  string
+		seen *http.Request
+	)
+
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got, seen = string(b), r
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+	if len(encoding) > 0 {
+		r.Header.Set("Content-Encoding", encoding)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	return rec, got, seen
+}
+
+func TestDecompressor(t *testing.T) {
+	plain := []byte(strings.Repeat("hello ", 100))
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		encoding := encoding
+		t.Run(encoding, func(t *testing.T) {
+			rec, got, r := decompressRequest(NewDecompressor(0, Logger(MinimalLevel, ioutil.Discard)),
+				encoding, compressed(t, encoding, plain))
+
+			if rec.Code != http.StatusOK || got != string(plain) {
+				t.Fatalf("expected the decompressed body, got %d %q", rec.Code, got)
+			}
+
+			if r.Header.Get("Content-Encoding") != "" || r.ContentLength != int64(len(plain)) {
+				t.Errorf("expected the headers to describe the decompressed body, got %v %d", r.Header, r.ContentLength)
+			}
+		})
+	}
+}
+
+func TestDecompressorPassThrough(t *testing.T) {
+	rec, got, _ := decompressRequest(NewDecompressor(0, Logger(MinimalLevel, ioutil.Discard)), "br", []byte("raw"))
+
+	if rec.Code != http.StatusOK || got != "raw" {
+		t.Errorf("expected other encodings passed through, got %d %q", rec.Code, got)
+	}
+}
+
+func TestDecompressorInvalid(t *testing.T) {
+	rec, _, _ := decompressRequest(NewDecompressor(0, Logger(MinimalLevel, ioutil.Discard)), "gzip", []byte("not gzip"))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestDecompressorLimitExceeded(t *testing.T) {
+	cases := []struct {
+		name   string
+		size   int
+		actual float64
+	}{
+		{name: "over the limit", size: 1500, actual: 1500},
+		{name: "far over the limit", size: 1 << 20, actual: decompressDrainFactor * 1000},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			body := compressed(t, "gzip", bytes.Repeat([]byte{'a'}, c.size))
+
+			called := false
+			h := NewDecompressor(1000, Logger(MinimalLevel, &out, WithFormat(JSONFormat))).Handler(
+				http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+
+			r := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+			r.Header.Set("Content-Encoding", "gzip")
+			r = r.WithContext(WithRequestID(r.Context(), "abc"))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusRequestEntityTooLarge || called {
+				t.Errorf("expected 413 without calling the handler, got %d, %t", rec.Code, called)
+			}
+
+			ev := jsonEvent(t, out.String(), "decompression_limit_exceeded")
+
+			if ev["request_id"] != "abc" || ev["size_limit"] != float64(1000) {
+				t.Errorf("unexpected event %v", ev)
+			}
+
+			if ev["actual_size"] != c.actual || ev["compressed_size"] != float64(len(body)) {
+				t.Errorf("expected actual size %v of compressed size %d, got %v", c.actual, len(body), ev)
+			}
+
+			if _, ok := ev["declared_size"]; ok {
+				t.Errorf("expected no declared size, got %v", ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultDedupMaxEntries = 1024
+
+// errorDedup tracks recently logged error responses so that repeated
+// identical errors can be collapsed into a single summary event.
+type errorDedup struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	order   []string
+
+	// stop ends the periodic flush; nil until it is started.
+	stop    chan struct{}
+	stopped bool
+}
+
+type dedupEntry struct {
+	method string
+	path   string
+	status int
+	since  time.Time
+	count  int
+}
+
+// summary returns the event reporting the occurrences suppressed since the
+// error was last logged in full.
+func (e *dedupEntry) summary() *LogEvent {
+	return &LogEvent{Kind: "repeated", Method: e.method, Path: e.path, Status: e.status, Count: e.count}
+}
+
+func dedupKey(r *http.Response, body []byte) (key, method, path string) {
+	if r.Request != nil {
+		method, path = r.Request.Method, r.Request.URL.Path
+	}
+
+	sum := sha256.Sum256(body)
+
+	return fmt.Sprintf("%s %s|%d|%s", method, path, r.StatusCode, hex.EncodeToString(sum[:])), method, path
+}
+
+// observe records an occurrence of the error identified by key. It reports
+// whether this occurrence should be logged in full, along with summaries for
+// suppressed occurrences that are due to be emitted.
+// nolint:lll
+func (d *errorDedup) observe(key, method, path string, status int, window time.Duration, limit int, now time.Time) (bool, []*LogEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.entries == nil {
+		d.entries = map[string]*dedupEntry{}
+	}
+
+	if limit <= 0 {
+		limit = defaultDedupMaxEntries
+	}
+
+	var summaries []*LogEvent
+
+	if e, ok := d.entries[key]; ok {
+		if now.Sub(e.since) < window {
+			e.count++
+
+			return false, nil
+		}
+
+		if e.count > 0 {
+			summaries = append(summaries, e.summary())
+		}
+
+		e.since = now
+		e.count = 0
+
+		return true, summaries
+	}
+
+	for len(d.order) >= limit {
+		oldest := d.order[0]
+		d.order = d.order[1:]
+
+		if e := d.entries[oldest]; e != nil && e.count > 0 {
+			summaries = append(summaries, e.summary())
+		}
+
+		delete(d.entries, oldest)
+	}
+
+	d.entries[key] = &dedupEntry{method: method, path: path, status: status, since: now}
+	d.order = append(d.order, key)
+
+	return true, summaries
+}
+
+// expire forgets the errors whose window has closed by now, or all of them
+// when all is set, returning summaries for those with suppressed occurrences.
+func (d *errorDedup) expire(window time.Duration, now time.Time, all bool) []*LogEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		summaries []*LogEvent
+		order     = d.order[:0]
+	)
+
+	for _, key := range d.order {
+		e := d.entries[key]
+		if !all && now.Sub(e.since) < window {
+			order = append(order, key)
+
+			continue
+		}
+
+		if e.count > 0 {
+			summaries = append(summaries, e.summary())
+		}
+
+		delete(d.entries, key)
+	}
+
+	d.order = order
+
+	return summaries
+}
+
+// start calls flush every interval until halt is called. Only the first call
+// has any effect.
+func (d *errorDedup) start(interval time.Duration, flush func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stop != nil {
+		return
+	}
+
+	d.stop = make(chan struct{})
+
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				flush()
+			case <-stop:
+				return
+			}
+		}
+	}(d.stop)
+}
+
+// halt stops the periodic flush.
+func (d *errorDedup) halt() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stop != nil && !d.stopped {
+		close(d.stop)
+		d.stopped = true
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func failing(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "boom", http.StatusInternalServerError)
+}
+
+func TestDedupSummaryOnClose(t *testing.T) {
+	var out syncBuffer
+
+	l := Logger(MinimalLevel, &out, WithFormat(JSONFormat))
+	l.DedupWindow = time.Hour
+
+	h := l.Handler(http.HandlerFunc(failing))
+	for i := 0; i < 5; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[string]int{}
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+
+		kind, _ := ev["kind"].(string)
+		kinds[kind]++
+
+		if kind == "repeated" {
+			if ev["count"] != 4.0 || ev["status"] != 500.0 || ev["method"] != "GET" || ev["path"] != "/x" {
+				t.Errorf("unexpected summary %v", ev)
+			}
+		}
+	}
+
+	if kinds["request"] != 1 || kinds["response"] != 1 || kinds["repeated"] != 1 {
+		t.Errorf("expected one request, response and summary, got %v", kinds)
+	}
+}
+
+func TestDedupPeriodicSummary(t *testing.T) {
+	var out syncBuffer
+
+	l := Logger(MinimalLevel, &out)
+	l.DedupWindow = 20 * time.Millisecond
+
+	defer l.Close() // nolint:errcheck
+
+	h := l.Handler(http.HandlerFunc(failing))
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "(repeated) GET /x status=500 count=2") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no summary logged without Close:\n%s", out.String())
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := strings.Count(out.String(), "(request)"); n != 1 {
+		t.Errorf("expected 1 request line, got %d:\n%s", n, out.String())
+	}
+}
+
+func TestDedupIgnoresSuccess(t *testing.T) {
+	var out syncBuffer
+
+	l := Logger(MinimalLevel, &out)
+	l.DedupWindow = time.Hour
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	}
+
+	if n := strings.Count(out.String(), "(response)"); n != 3 {
+		t.Errorf("expected 3 response lines, got %d:\n%s", n, out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultETagMaxBytes = 1 << 20
+
+// NewETag returns a handler that adds ETags to successful GET and HEAD
+// responses and answers matching conditional requests with 304 Not Modified.
+func NewETag() *ETag {
+	return &ETag{}
+}
+
+// ETag is the handler responsible for conditional GETs. It holds back each
+// response to compute a strong ETag from the SHA-256 of its body, unless the
+// handler set its own. Responses that are flushed, are server-sent event
+// streams, or exceed MaxBytes pass through without an ETag.
+type ETag struct {
+	// MaxBytes is the largest response buffered. Defaults to 1MiB.
+	MaxBytes int64
+}
+
+// Handler implements the middleware interface.
+func (h *ETag) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		limit := h.MaxBytes
+		if limit <= 0 {
+			limit = defaultETagMaxBytes
+		}
+
+		cw := newCaptureWriter(w, limit)
+		cw.held = true
+		cw.discard = true
+
+		next.ServeHTTP(cw, r)
+		finishETag(cw, r)
+	})
+}
+
+func (h *ETag) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+// finishETag tags the response still held by w, and either answers 304 Not
+// Modified or releases it.
+func finishETag(w *captureWriter, r *http.Request) {
+	if !w.held {
+		return
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	tag := w.Header().Get("ETag")
+	if w.status == http.StatusOK && len(tag) == 0 && (w.body.Len() > 0 || r.Method == http.MethodGet) {
+		sum := sha256.Sum256(w.body.Bytes())
+		tag = strconv.Quote(hex.EncodeToString(sum[:]))
+		w.Header().Set("ETag", tag)
+	}
+
+	if w.status == http.StatusOK && len(tag) > 0 && etagMatch(r.Header.Get("If-None-Match"), tag) {
+		h := w.Header()
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+
+		w.held = false
+		w.status = http.StatusNotModified
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.release()
+}
+
+// etagMatch reports whether the If-None-Match header value matches tag, using
+// the weak comparison required for If-None-Match.
+func etagMatch(ifNoneMatch, tag string) bool {
+	if len(ifNoneMatch) == 0 {
+		return false
+	}
+
+	tag = strings.TrimPrefix(tag, "W/")
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
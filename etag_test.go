@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func etagRequest(h http.Handler, method, ifNoneMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	if len(ifNoneMatch) > 0 {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestETag(t *testing.T) {
+	h := NewETag().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello")) // nolint:errcheck
+	}))
+
+	w := etagRequest(h, http.MethodGet, "")
+	tag := w.Header().Get("ETag")
+
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("expected 200 hello, got %d %q", w.Code, w.Body.String())
+	}
+
+	if !strings.HasPrefix(tag, `"`) || len(tag) != 66 {
+		t.Fatalf("expected a strong sha256 ETag, got %q", tag)
+	}
+
+	for _, match := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+		w = etagRequest(h, http.MethodGet, match)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty 304, got %d %q", match, w.Code, w.Body.String())
+		}
+
+		if got := w.Header().Get("ETag"); got != tag {
+			t.Errorf("%s: expected ETag %s on the 304, got %q", match, tag, got)
+		}
+	}
+
+	if w = etagRequest(h, http.MethodGet, `"other"`); w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("expected 200 hello for a stale tag, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestETagKeepsHandlerTag(t *testing.T) {
+	h := NewETag().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello")) // nolint:errcheck
+	}))
+
+	if w := etagRequest(h, http.MethodGet, ""); w.Header().Get("ETag") != `"v1"` {
+		t.Errorf("expected the handler ETag, got %q", w.Header().Get("ETag"))
+	}
+
+	if w := etagRequest(h, http.MethodGet, `"v1"`); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+}
+
+func TestETagSkipsErrorsAndUnsafeMethods(t *testing.T) {
+	h := NewETag().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.Error(w, "missing", http.StatusNotFound)
+
+			return
+		}
+
+		w.Write([]byte("created")) // nolint:errcheck
+	}))
+
+	if w := etagRequest(h, http.MethodGet, "*"); w.Code != http.StatusNotFound || len(w.Header().Get("ETag")) > 0 {
+		t.Errorf("expected an untagged 404, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	if w := etagRequest(h, http.MethodPost, "*"); w.Code != http.StatusOK || len(w.Header().Get("ETag")) > 0 {
+		t.Errorf("expected an untagged 200, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestETagPassesThrough(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"flushed": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("part")) // nolint:errcheck
+			w.(http.Flusher).Flush()
+		},
+		"oversized": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("more than ten bytes")) // nolint:errcheck
+		},
+		"stream": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: x\n\n")) // nolint:errcheck
+		},
+	} {
+		e := NewETag()
+		e.MaxBytes = 10
+
+		w := etagRequest(e.Handler(handler), http.MethodGet, "*")
+
+		if w.Code != http.StatusOK || w.Body.Len() == 0 || len(w.Header().Get("ETag")) > 0 {
+			t.Errorf("%s: expected an untagged 200 with a body, got %d %q %q",
+				name, w.Code, w.Header().Get("ETag"), w.Body.String())
+		}
+	}
+}
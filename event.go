@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Format selects how log entries are serialized.
+type Format int
+
+// Format options.
+const (
+	TextFormat Format = iota
+	LogfmtFormat
+	JSONFormat
+)
+
+// LogEvent is the structured form of a single log entry.
+type LogEvent struct {
+	Kind      string
+	RequestID string
+	// TraceID, SpanID and ParentSpanID place the entry in a W3C trace.
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Host         string
+	ClientIP     string
+	Method       string
+	Path         string
+	Status       int
+	// Count is the number of occurrences of a repeated error that were not
+	// logged individually.
+	Count   int
+	Error   string
+	Headers http.Header
+	Body    string
+	// Bytes is the number of response body bytes written, so far in the case
+	// of progress events. Client responses report their Content-Length.
+	Bytes int64
+	// Duration is the time elapsed since the request started.
+	Duration time.Duration
+	Fanout   int64
+	// RemainingBudget is the time left before the request context deadline,
+	// when there is one.
+	RemainingBudget *time.Duration
+	Panic           string
+	Stack           string
+	// DeclaredSize, ActualSize and SizeLimit describe a body that exceeded a
+	// size limit. CompressedSize is the Content-Length of a compressed body
+	// whose decompressed size is given by ActualSize.
+	DeclaredSize   int64
+	ActualSize     int64
+	SizeLimit      int64
+	CompressedSize int64
+}
+
+type eventField struct {
+	key   string
+	value interface{}
+}
+
+// fields returns the populated fields of the event in a stable order.
+func (e *LogEvent) fields() []eventField {
+	fields := []eventField{{"kind", e.Kind}}
+
+	add := func(key string, value interface{}, present bool) {
+		if present {
+			fields = append(fields, eventField{key, value})
+		}
+	}
+
+	add("request_id", e.RequestID, len(e.RequestID) > 0)
+	add("trace_id", e.TraceID, len(e.TraceID) > 0)
+	add("span_id", e.SpanID, len(e.SpanID) > 0)
+	add("parent_span_id", e.ParentSpanID, len(e.ParentSpanID) > 0)
+	add("host", e.Host, len(e.Host) > 0)
+	add("client_ip", e.ClientIP, len(e.ClientIP) > 0)
+	add("method", e.Method, len(e.Method) > 0)
+	add("path", e.Path, len(e.Path) > 0)
+	add("status", e.Status, e.Status != 0)
+	add("count", e.Count, e.Count != 0)
+	add("error", e.Error, len(e.Error) > 0)
+	add("headers", e.Headers, len(e.Headers) > 0)
+	add("body", e.Body, len(e.Body) > 0)
+	add("bytes", e.Bytes, e.Bytes != 0)
+	add("duration", e.Duration, e.Duration != 0)
+	add("fanout", e.Fanout, e.Fanout != 0)
+
+	if e.RemainingBudget != nil {
+		add("remaining_budget", *e.RemainingBudget, true)
+	}
+
+	add("panic", e.Panic, len(e.Panic) > 0)
+	add("stack", e.Stack, len(e.Stack) > 0)
+	add("declared_size", e.DeclaredSize, e.DeclaredSize != 0)
+	add("compressed_size", e.CompressedSize, e.CompressedSize > 0)
+	add("actual_size", e.ActualSize, e.DeclaredSize != 0 || e.SizeLimit != 0)
+	add("size_limit", e.SizeLimit, e.SizeLimit != 0)
+
+	return fields
+}
+
+func (e *LogEvent) writeText(w io.Writer) error {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%11s ", "("+e.Kind+")")
+
+	if len(e.RequestID) > 0 {
+		fmt.Fprintf(&buf, "[%s] ", e.RequestID)
+	}
+
+	fmt.Fprintf(&buf, "%s %s", e.Method, e.Path)
+
+	if len(e.Panic) > 0 {
+		fmt.Fprintf(&buf, ": %s\n", e.Panic)
+
+		if len(e.Stack) > 0 {
+			fmt.Fprintf(&buf, "%s\n", e.Stack)
+		}
+	} else {
+		for _, f := range e.fields() {
+			switch f.key {
+			case "kind", "request_id", "method", "path":
+				continue
+			}
+
+			writeLogfmtPair(&buf, f.key, fmt.Sprint(f.value))
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+func (e *LogEvent) writeLogfmt(w io.Writer) error {
+	var buf bytes.Buffer
+
+	writeLogfmtFields(&buf, e.fields())
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+func (e *LogEvent) writeJSON(w io.Writer) error {
+	var buf bytes.Buffer
+
+	if err := writeJSONFields(&buf, e.fields()); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// writeJSONFields writes the fields as a single line JSON object, keeping
+// their order. Durations are written in their string form.
+func writeJSONFields(buf *bytes.Buffer, fields []eventField) error {
+	buf.WriteByte('{')
+
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		value := f.value
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		key, _ := json.Marshal(f.key)
+
+		v, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteString("}\n")
+
+	return nil
+}
+
+// writeLogfmtFields writes the fields as a logfmt line, flattening headers
+// into one key per header name.
+func writeLogfmtFields(buf *bytes.Buffer, fields []eventField) {
+	for _, f := range fields {
+		h, ok := f.value.(http.Header)
+		if !ok {
+			writeLogfmtPair(buf, f.key, fmt.Sprint(f.value))
+
+			continue
+		}
+
+		for _, k := range sortedHeaderKeys(h) {
+			writeLogfmtPair(buf, f.key+"."+k, joinHeaderValues(h[k]))
+		}
+	}
+
+	buf.WriteByte('\n')
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString(key)
+	buf.WriteByte('=')
+
+	if logfmtNeedsQuote(value) {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+func logfmtNeedsQuote(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
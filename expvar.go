@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// ExpvarHandler returns an http.Handler serving the published expvar
+// variables as JSON at `/vars`.
+func ExpvarHandler() http.Handler {
+	return ExpvarHandlerWithPath("/vars")
+}
+
+// ExpvarHandlerWithPath returns an http.Handler serving the published expvar
+// variables as JSON at path.
+func ExpvarHandlerWithPath(path string) http.Handler {
+	m := http.NewServeMux()
+	m.Handle(path, expvar.Handler())
+
+	return m
+}
+
+// ExpvarHandlerWithAuth returns the ExpvarHandler endpoint guarded by
+// authorize, responding 403 Forbidden to requests it rejects.
+func ExpvarHandlerWithAuth(authorize func(*http.Request) bool) http.Handler {
+	next := ExpvarHandler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
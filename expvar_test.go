@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testExpvar = expvar.NewInt("middleware_test_requests")
+
+func expvarGet(h http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	return rec
+}
+
+func TestExpvarHandler(t *testing.T) {
+	testExpvar.Set(42)
+
+	rec := expvarGet(ExpvarHandler(), "/vars")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", rec.Body.String(), err)
+	}
+
+	if got := vars["middleware_test_requests"]; got != float64(42) {
+		t.Errorf("expected the registered var, got %v", got)
+	}
+
+	if _, ok := vars["memstats"]; !ok {
+		t.Error("expected the standard memstats var")
+	}
+
+	if rec := expvarGet(ExpvarHandler(), "/other"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 elsewhere, got %d", rec.Code)
+	}
+}
+
+func TestExpvarHandlerWithPath(t *testing.T) {
+	h := ExpvarHandlerWithPath("/debug/vars")
+
+	if rec := expvarGet(h, "/debug/vars"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+
+	if rec := expvarGet(h, "/vars"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 at the default path, got %d", rec.Code)
+	}
+}
+
+func TestExpvarHandlerWithAuth(t *testing.T) {
+	h := ExpvarHandlerWithAuth(func(r *http.Request) bool { return r.Header.Get("X-Token") == "ok" })
+
+	if rec := expvarGet(h, "/vars"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/vars", nil)
+	r.Header.Set("X-Token", "ok")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const fanoutKeyName contextKey = "fanout-counter-key"
+
+// FanoutContextKey is the context key under which the fan-out counter is
+// stored.
+const FanoutContextKey = fanoutKeyName
+
+// WithFanoutCounter adds a counter of downstream calls into the context.
+func WithFanoutCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fanoutKeyName, new(int64))
+}
+
+// GetFanout returns the number of downstream calls made with the context and
+// true if the context carries a counter.
+func GetFanout(ctx context.Context) (int64, bool) {
+	n, ok := ctx.Value(fanoutKeyName).(*int64)
+	if !ok {
+		return 0, false
+	}
+
+	return atomic.LoadInt64(n), true
+}
+
+func incrementFanout(ctx context.Context) {
+	if n, ok := ctx.Value(fanoutKeyName).(*int64); ok {
+		atomic.AddInt64(n, 1)
+	}
+}
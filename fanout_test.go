@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// downstream answers 200, or fails with the context error once the request
+// context is done.
+func downstream() http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := r.Context().Err(); err != nil {
+			return nil, err
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: r}, nil
+	})
+}
+
+func fanoutHandler(t *testing.T, call func(ctx context.Context, client *http.Client)) float64 {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	client := &http.Client{Transport: NewRoundTripLogger(downstream(), NoneLevel, ioutil.Discard, log.New(ioutil.Discard, "", 0))}
+
+	h := Logger(MinimalLevel, &out, WithFormat(JSONFormat)).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			call(r.Context(), client)
+		}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	n, _ := jsonEvent(t, out.String(), "response")["fanout"].(float64)
+
+	return n
+}
+
+func downstreamGet(ctx context.Context, client *http.Client) error {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://downstream.example/", nil)
+
+	res, err := client.Do(r)
+	if err == nil {
+		res.Body.Close() // nolint:errcheck
+	}
+
+	return err
+}
+
+func TestFanoutCounted(t *testing.T) {
+	n := fanoutHandler(t, func(ctx context.Context, client *http.Client) {
+		for i := 0; i < 3; i++ {
+			downstreamGet(ctx, client) // nolint:errcheck
+		}
+	})
+
+	if n != 3 {
+		t.Errorf("expected fanout 3, got %v", n)
+	}
+}
+
+func TestFanoutConcurrent(t *testing.T) {
+	n := fanoutHandler(t, func(ctx context.Context, client *http.Client) {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				downstreamGet(ctx, client) // nolint:errcheck
+			}()
+		}
+
+		wg.Wait()
+	})
+
+	if n != 20 {
+		t.Errorf("expected fanout 20, got %v", n)
+	}
+}
+
+func TestFanoutCancelledAndFailed(t *testing.T) {
+	var errs []error
+
+	n := fanoutHandler(t, func(ctx context.Context, client *http.Client) {
+		errs = append(errs, downstreamGet(ctx, client))
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		for i := 0; i < 2; i++ {
+			errs = append(errs, downstreamGet(cctx, client))
+		}
+	})
+
+	if n != 3 {
+		t.Errorf("expected failed calls counted too, got fanout %v", n)
+	}
+
+	var failed int
+
+	for _, err := range errs {
+		if errors.Is(err, context.Canceled) {
+			failed++
+		}
+	}
+
+	if failed != 2 {
+		t.Errorf("expected both cancelled calls to fail, got %v", errs)
+	}
+}
+
+func TestFanoutNone(t *testing.T) {
+	if n := fanoutHandler(t, func(context.Context, *http.Client) {}); n != 0 {
+		t.Errorf("expected no fanout logged, got %v", n)
+	}
+
+	if _, ok := GetFanout(context.Background()); ok {
+		t.Error("expected no counter outside the logger")
+	}
+
+	// A call outside a logged request has no counter to increment.
+	client := &http.Client{Transport: NewRoundTripLogger(downstream(), NoneLevel, ioutil.Discard, log.New(ioutil.Discard, "", 0))}
+	if err := downstreamGet(context.Background(), client); err != nil {
+		t.Errorf("expected the call to succeed, got %v", err)
+	}
+}
+
+func TestFanoutUpstreamCounter(t *testing.T) {
+	ctx := WithFanoutCounter(context.Background())
+
+	h := Logger(MinimalLevel, ioutil.Discard).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		incrementFanout(r.Context())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if n, _ := GetFanout(ctx); n != 1 {
+		t.Errorf("expected the upstream counter shared, got %d", n)
+	}
+}
@@ -1,27 +1,237 @@
 package middleware
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // HealthzHandler returns an http.Handler for the // `/healthz` endpoint and a
 // debugging endpoint at `/healthz/toggle` // that will toggle the health report.
 func HealthzHandler() http.Handler {
-	h := &healthz{ok: true}
+	return NewHealthz()
+}
+
+// NewHealthz returns a Healthz that reports healthy until a registered check
+// fails or it is toggled.
+func NewHealthz() *Healthz {
+	h := &Healthz{mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("/toggle", h.handleToggle)
+	h.mux.HandleFunc("/", h.handleCheck)
+
+	return h
+}
+
+// Healthz serves the health report at its root and a debugging endpoint at
+// `/toggle` that toggles it.
+type Healthz struct {
+	unhealthy int32 // accessed atomically
+
+	// CheckTimeout bounds each check registered without a timeout of its
+	// own; a check still running when it expires is reported as failed.
+	// Defaults to 5s.
+	CheckTimeout time.Duration
+
+	mu     sync.Mutex
+	checks []healthCheck
+
+	mux *http.ServeMux
+}
+
+const defaultCheckTimeout = 5 * time.Second
+
+type healthCheck struct {
+	name    string
+	timeout time.Duration
+	check   func(ctx context.Context) error
+}
+
+// RegisterCheck adds a named check, such as a database ping, that must pass
+// for the service to report healthy.
+func (h *Healthz) RegisterCheck(name string, check func(ctx context.Context) error) {
+	h.RegisterCheckWithTimeout(name, 0, check)
+}
+
+// RegisterCheckWithTimeout adds a named check that fails if it does not
+// complete within timeout. A zero timeout uses CheckTimeout.
+// nolint:lll
+func (h *Healthz) RegisterCheckWithTimeout(name string, timeout time.Duration, check func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, healthCheck{name: name, timeout: timeout, check: check})
+}
+
+// run runs the check, giving up once its timeout expires even if the check
+// ignores its context.
+func (c healthCheck) run(ctx context.Context, timeout time.Duration) error {
+	if c.timeout > 0 {
+		timeout = c.timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- c.check(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+
+		return ctx.Err()
+	}
+}
+
+// SetHealthy marks the service healthy or not, independently of the checks.
+func (h *Healthz) SetHealthy(ok bool) {
+	var unhealthy int32
+	if !ok {
+		unhealthy = 1
+	}
+
+	atomic.StoreInt32(&h.unhealthy, unhealthy)
+}
+
+// Healthy reports whether the service is marked healthy, ignoring the checks.
+func (h *Healthz) Healthy() bool {
+	return atomic.LoadInt32(&h.unhealthy) == 0
+}
+
+// UnhealthyOnDone marks the service unhealthy once ctx is done. Passing a
+// context that is cancelled on SIGTERM, such as one from signal.NotifyContext,
+// makes readiness fail as soon as shutdown starts, so that load balancers
+// drain the instance before it exits.
+func (h *Healthz) UnhealthyOnDone(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		h.SetHealthy(false)
+	}()
+}
 
-	mux := http.NewServeMux()
+// toggle flips the health mark, returning the new value.
+func (h *Healthz) toggle() bool {
+	for {
+		old := atomic.LoadInt32(&h.unhealthy)
+		if atomic.CompareAndSwapInt32(&h.unhealthy, old, 1-old) {
+			return old == 1
+		}
+	}
+}
 
-	mux.HandleFunc("/toggle", h.handleToggle)
-	mux.HandleFunc("/", h.handleCheck)
+func (h *Healthz) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
 
-	return mux
+// healthReport is the JSON form of the health report.
+type healthReport struct {
+	Status string         `json:"status"`
+	Checks []healthResult `json:"checks"`
 }
 
-type healthz struct {
-	ok bool
+type healthResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
 }
 
-func (h *healthz) handleCheck(w http.ResponseWriter, r *http.Request) {
-	if !h.ok {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+func (h *Healthz) report(ctx context.Context) healthReport {
+	h.mu.Lock()
+	checks := h.checks
+	h.mu.Unlock()
+
+	report := healthReport{Status: healthOK, Checks: []healthResult{}}
+	if !h.Healthy() {
+		report.Status = healthFail
+	}
+
+	timeout := h.CheckTimeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	results := make([]healthResult, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		wg.Add(1)
+
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.run(ctx, timeout)
+
+			results[i] = healthResult{Name: c.name, Status: healthOK, Duration: time.Since(start).String()}
+			if err != nil {
+				results[i].Status = healthFail
+				results[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status != healthOK {
+			report.Status = healthFail
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+func (h *Healthz) handleCheck(w http.ResponseWriter, r *http.Request) {
+	report := h.report(r.Context())
+
+	status := http.StatusOK
+	if report.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	if NegotiateContentType(r.Header, []string{"text/plain", "application/json"}, "text/plain") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report) // nolint:errcheck
+
+		return
+	}
+
+	if status != http.StatusOK {
+		var failures []string
+
+		for _, c := range report.Checks {
+			if len(c.Error) > 0 {
+				failures = append(failures, fmt.Sprintf("%s: %s", c.Name, c.Error))
+			}
+		}
+
+		msg := http.StatusText(status)
+		if len(failures) > 0 {
+			msg = strings.Join(failures, "\n")
+		}
+
+		http.Error(w, msg, status)
 
 		return
 	}
@@ -30,11 +240,10 @@ func (h *healthz) handleCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK")) // nolint:errcheck
 }
 
-func (h *healthz) handleToggle(w http.ResponseWriter, r *http.Request) {
+func (h *Healthz) handleToggle(w http.ResponseWriter, r *http.Request) {
 	status := "good"
 
-	h.ok = !h.ok
-	if !h.ok {
+	if !h.toggle() {
 		status = "bad"
 	}
 
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func healthzGet(h http.Handler, path, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if len(accept) > 0 {
+		r.Header.Set("Accept", accept)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func passingCheck(context.Context) error { return nil }
+
+func TestHealthzNoChecks(t *testing.T) {
+	w := healthzGet(HealthzHandler(), "/", "")
+
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Errorf("expected 200 OK, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHealthzChecks(t *testing.T) {
+	hz := NewHealthz()
+	hz.RegisterCheck("db", passingCheck)
+	hz.RegisterCheck("cache", passingCheck)
+
+	if w := healthzGet(hz, "/", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 with passing checks, got %d", w.Code)
+	}
+
+	hz.RegisterCheck("queue", func(context.Context) error { return errors.New("connection refused") })
+
+	w := healthzGet(hz, "/", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with a failing check, got %d", w.Code)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "queue: connection refused") || strings.Contains(body, "db") {
+		t.Errorf("expected only the failing check reported, got %q", body)
+	}
+}
+
+func TestHealthzChecksReceiveContext(t *testing.T) {
+	type key struct{}
+
+	hz := NewHealthz()
+	hz.RegisterCheck("ctx", func(ctx context.Context) error {
+		if ctx.Value(key{}) != "value" {
+			return errors.New("missing request context")
+		}
+
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), key{}, "value"))
+
+	w := httptest.NewRecorder()
+	hz.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the check to run with the request context, got %d %q", w.Code, w.Body.String())
+	}
+}
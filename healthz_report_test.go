@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthzJSONReport(t *testing.T) {
+	hz := NewHealthz()
+	hz.RegisterCheck("db", passingCheck)
+	hz.RegisterCheck("queue", func(context.Context) error { return errors.New("connection refused") })
+
+	w := healthzGet(hz, "/", "application/json")
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON content type, got %q", ct)
+	}
+
+	var report struct {
+		Status string `json:"status"`
+		Checks []struct {
+			Name     string `json:"name"`
+			Status   string `json:"status"`
+			Error    string `json:"error"`
+			Duration string `json:"duration"`
+		} `json:"checks"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != "fail" || len(report.Checks) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	db, queue := report.Checks[0], report.Checks[1]
+
+	if db.Name != "db" || db.Status != "ok" || len(db.Error) > 0 {
+		t.Errorf("unexpected db result %+v", db)
+	}
+
+	if queue.Name != "queue" || queue.Status != "fail" || queue.Error != "connection refused" {
+		t.Errorf("unexpected queue result %+v", queue)
+	}
+
+	for _, c := range report.Checks {
+		if _, err := time.ParseDuration(c.Duration); err != nil {
+			t.Errorf("expected a duration for %s, got %q", c.Name, c.Duration)
+		}
+	}
+}
+
+func TestHealthzJSONReportHealthy(t *testing.T) {
+	w := healthzGet(NewHealthz(), "/", "application/json")
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Body.String(); got != "{\"status\":\"ok\",\"checks\":[]}\n" {
+		t.Errorf("unexpected report %q", got)
+	}
+}
+
+func TestHealthzPlainTextByDefault(t *testing.T) {
+	for _, accept := range []string{"", "text/html", "text/plain, application/json;q=0.5"} {
+		if w := healthzGet(NewHealthz(), "/", accept); w.Body.String() != "OK" {
+			t.Errorf("expected a plain text report for %q, got %q", accept, w.Body.String())
+		}
+	}
+}
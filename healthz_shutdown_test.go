@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthzUnhealthyOnDone(t *testing.T) {
+	hz := NewHealthz()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hz.UnhealthyOnDone(ctx)
+
+	if w := healthzGet(hz, "/", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 before cancel, got %d", w.Code)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for hz.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if w := healthzGet(hz, "/", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after cancel, got %d", w.Code)
+	}
+}
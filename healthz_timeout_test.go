@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthzCheckTimeout(t *testing.T) {
+	hz := NewHealthz()
+	hz.CheckTimeout = 20 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// The hung check ignores its context, like a driver without timeouts.
+	hz.RegisterCheck("hung", func(context.Context) error {
+		<-release
+
+		return nil
+	})
+	hz.RegisterCheck("db", passingCheck)
+
+	start := time.Now()
+	w := healthzGet(hz, "/", "")
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the probe not to hang, took %v", elapsed)
+	}
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "hung: timed out after 20ms") {
+		t.Errorf("expected the timeout reported, got %q", w.Body.String())
+	}
+}
+
+func TestHealthzCheckOwnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	hz := NewHealthz()
+	hz.RegisterCheckWithTimeout("slow", 10*time.Millisecond, func(context.Context) error {
+		<-release
+
+		return nil
+	})
+
+	w := healthzGet(hz, "/", "")
+
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "slow: timed out after 10ms") {
+		t.Errorf("expected the check's own timeout reported, got %d %q", w.Code, w.Body.String())
+	}
+}
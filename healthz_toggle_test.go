@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestHealthzSetHealthy(t *testing.T) {
+	hz := NewHealthz()
+
+	hz.SetHealthy(false)
+
+	if w := healthzGet(hz, "/", ""); w.Code != http.StatusServiceUnavailable || hz.Healthy() {
+		t.Errorf("expected 503 when unhealthy, got %d", w.Code)
+	}
+
+	hz.SetHealthy(true)
+
+	if w := healthzGet(hz, "/", ""); w.Code != http.StatusOK || !hz.Healthy() {
+		t.Errorf("expected 200 when healthy, got %d", w.Code)
+	}
+}
+
+func TestHealthzToggle(t *testing.T) {
+	hz := NewHealthz()
+
+	if w := healthzGet(hz, "/toggle", ""); w.Body.String() != "status is: bad" || hz.Healthy() {
+		t.Errorf("expected the first toggle to mark it unhealthy, got %q", w.Body.String())
+	}
+
+	if w := healthzGet(hz, "/toggle", ""); w.Body.String() != "status is: good" || !hz.Healthy() {
+		t.Errorf("expected the second toggle to mark it healthy, got %q", w.Body.String())
+	}
+}
+
+// TestHealthzToggleConcurrently is meant to be run with -race.
+func TestHealthzToggleConcurrently(t *testing.T) {
+	const n = 100
+
+	hz := NewHealthz()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			healthzGet(hz, "/toggle", "")
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			hz.SetHealthy(i%2 == 0)
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			if w := healthzGet(hz, "/", ""); w.Code != http.StatusOK && w.Code != http.StatusServiceUnavailable {
+				t.Errorf("unexpected status %d", w.Code)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -1,9 +1,15 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
 	"mime"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"text/template"
+	"text/template/parse"
 )
 
 func matchAny(t string, options ...string) bool {
@@ -16,10 +22,13 @@ func matchAny(t string, options ...string) bool {
 	return false
 }
 
-func hasContentType(h http.Header, mimetypes ...string) bool {
+// HasContentType reports whether the Content-Type in h matches one of the
+// given media types, which may be ranges such as "text/*" or "*/*". A missing
+// Content-Type is treated as application/octet-stream.
+func HasContentType(h http.Header, mimetypes ...string) bool {
 	ct := h.Get("Content-Type")
 	if len(ct) == 0 {
-		return matchAny("application/octet-stream", mimetypes...)
+		return matchMediaRange("application/octet-stream", mimetypes...)
 	}
 
 	for _, p := range strings.Split(ct, ",") {
@@ -28,10 +37,419 @@ func hasContentType(h http.Header, mimetypes ...string) bool {
 			continue
 		}
 
-		if matchAny(mt, mimetypes...) {
+		if matchMediaRange(mt, mimetypes...) {
 			return true
 		}
 	}
 
 	return false
 }
+
+// matchMediaRange reports whether the media type mt matches one of the
+// ranges, compared case-insensitively.
+func matchMediaRange(mt string, ranges ...string) bool {
+	typ, sub := splitMediaType(mt)
+
+	for _, r := range ranges {
+		rtyp, rsub := splitMediaType(r)
+
+		switch {
+		case rtyp == "*" && rsub == "*":
+			return true
+		case rtyp == typ && (rsub == "*" || rsub == sub):
+			return true
+		}
+	}
+
+	return false
+}
+
+// NegotiateContentType returns the media type in offered that best matches
+// the Accept header in h, honoring q-values and media ranges such as
+// "application/*". Ties go to the more specific range, then to the earlier
+// offer. defaultType is returned when there is no Accept header or nothing
+// offered is acceptable.
+func NegotiateContentType(h http.Header, offered []string, defaultType string) string {
+	accept := h.Values("Accept")
+	if len(accept) == 0 {
+		return defaultType
+	}
+
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	var (
+		best            = defaultType
+		bestQ           float64
+		bestSpecificity = -1
+	)
+
+	for _, offer := range offered {
+		typ, sub := splitMediaType(offer)
+
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := r.matches(typ, sub)
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+
+		if specificity < 0 || q <= 0 {
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+
+	return best
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+// matches returns how specifically the range matches typ/sub: 2 for an exact
+// match, 1 for a subtype wildcard, 0 for */* and -1 for no match.
+func (r acceptRange) matches(typ, sub string) int {
+	switch {
+	case r.typ == "*" && r.sub == "*":
+		return 0
+	case r.typ != typ:
+		return -1
+	case r.sub == "*":
+		return 1
+	case r.sub == sub:
+		return 2
+	}
+
+	return -1
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		typ, sub := splitMediaType(params[0])
+		if len(typ) == 0 || len(sub) == 0 {
+			continue
+		}
+
+		r := acceptRange{typ: typ, sub: sub, q: 1}
+
+		for _, p := range params[1:] {
+			k, v := p, ""
+			if i := strings.Index(p, "="); i >= 0 {
+				k, v = p[:i], p[i+1:]
+			}
+
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+func splitMediaType(mt string) (typ, sub string) {
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	if i := strings.Index(mt, "/"); i >= 0 {
+		return mt[:i], mt[i+1:]
+	}
+
+	return mt, ""
+}
+
+// nolint:gochecknoglobals
+var textualMediaTypes = []string{
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/x-www-form-urlencoded",
+	"application/x-ndjson",
+	"application/graphql",
+}
+
+// isTextual reports whether a body with the given headers is human readable,
+// judging by its declared content type and falling back to sniffing the body.
+func isTextual(h http.Header, body []byte) bool {
+	if ct := h.Get("Content-Type"); len(ct) > 0 && textualContentType(ct) {
+		return true
+	}
+
+	return textualContentType(http.DetectContentType(body))
+}
+
+func textualContentType(ct string) bool {
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := params["charset"]; ok {
+		return true
+	}
+
+	return strings.HasPrefix(mt, "text/") ||
+		strings.HasSuffix(mt, "+json") ||
+		strings.HasSuffix(mt, "+xml") ||
+		matchAny(mt, textualMediaTypes...)
+}
+
+// clientIP returns the client address of r, taken from the first of the
+// trusted proxy headers present and falling back to r.RemoteAddr. Each proxy
+// appends the address it was connected from to a list such as X-Forwarded-For,
+// so with the given number of trusted proxies the client is that many entries
+// from the right, and any entries left of it may be forged. A count below one
+// counts as one.
+func clientIP(r *http.Request, trustedHeaders []string, proxies int) string {
+	if proxies < 1 {
+		proxies = 1
+	}
+
+	for _, name := range trustedHeaders {
+		v := r.Header.Get(name)
+		if len(v) == 0 {
+			continue
+		}
+
+		hops := strings.Split(v, ",")
+		if len(hops) < proxies {
+			continue
+		}
+
+		if ip := strings.TrimSpace(hops[len(hops)-proxies]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// isJSON reports whether the Content-Type in h is a JSON media type.
+func isJSON(h http.Header) bool {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// prettyJSON re-indents body when h declares it as JSON, returning it
+// unchanged when it is not JSON or does not parse.
+func prettyJSON(h http.Header, body string) string {
+	if len(body) == 0 || !isJSON(h) {
+		return body
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+		return body
+	}
+
+	return buf.String()
+}
+
+// redactSetCookies returns the Set-Cookie values with each cookie value
+// redacted, keeping the cookie names and attributes.
+func redactSetCookies(values []string, redact func(name, value string) string) []string {
+	redacted := make([]string, len(values))
+
+	for i, v := range values {
+		pair, attrs := v, ""
+		if j := strings.Index(v, ";"); j >= 0 {
+			pair, attrs = v[:j], v[j:]
+		}
+
+		j := strings.Index(pair, "=")
+		if j < 0 {
+			redacted[i] = redact("", pair) + attrs
+
+			continue
+		}
+
+		name := strings.TrimSpace(pair[:j])
+		redacted[i] = name + "=" + redact(name, pair[j+1:]) + attrs
+	}
+
+	return redacted
+}
+
+// redactCookies returns the Cookie header values with the values of the named
+// cookies redacted, or of every cookie when names is empty.
+func redactCookies(values []string, names []string, redact func(name, value string) string) []string {
+	redacted := make([]string, len(values))
+
+	for i, v := range values {
+		cookies := strings.Split(v, ";")
+		for j, c := range cookies {
+			c = strings.TrimSpace(c)
+
+			name, value := c, ""
+			if k := strings.Index(c, "="); k >= 0 {
+				name, value = c[:k], c[k+1:]
+			}
+
+			if len(names) == 0 || matchAny(name, names...) {
+				c = name + "=" + redact(name, value)
+			}
+
+			cookies[j] = c
+		}
+
+		redacted[i] = strings.Join(cookies, "; ")
+	}
+
+	return redacted
+}
+
+// redactJSON redacts, in place, the values in v found at the given paths of
+// object keys, compared case-insensitively. Arrays are descended into without
+// consuming a path element. Values other than strings are passed to redact as
+// JSON.
+func redactJSON(v interface{}, paths [][]string, redact func(name, value string) string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			var rest [][]string
+
+			for _, p := range paths {
+				if len(p) == 0 || !strings.EqualFold(p[0], k) {
+					continue
+				}
+
+				if len(p) == 1 {
+					t[k] = redact(k, jsonText(child))
+					rest = nil
+
+					break
+				}
+
+				rest = append(rest, p[1:])
+			}
+
+			if len(rest) > 0 {
+				t[k] = redactJSON(child, rest, redact)
+			}
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = redactJSON(child, paths, redact)
+		}
+	}
+
+	return v
+}
+
+func jsonText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(v)
+
+	return string(b)
+}
+
+// maskTail replaces all but the last n characters of s with asterisks. Values
+// no longer than n are masked entirely.
+func maskTail(n int, s string) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return strings.Repeat("*", len(r))
+	}
+
+	if n < 0 {
+		n = 0
+	}
+
+	return strings.Repeat("*", len(r)-n) + string(r[len(r)-n:])
+}
+
+// usesField reports whether t, or a template associated with it, may refer to
+// the named field of its data. Passing the data on whole, to a function or
+// another template, counts as referring to it.
+func usesField(t *template.Template, field string) bool {
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil && nodeUsesField(tt.Tree.Root, field, true) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeUsesField walks node, where root reports whether dot is still the
+// template data rather than a value selected by with or range.
+// nolint:gocyclo
+func nodeUsesField(node parse.Node, field string, root bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+
+		for _, c := range n.Nodes {
+			if nodeUsesField(c, field, root) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeUsesField(n.Pipe, field, root)
+	case *parse.IfNode:
+		return nodeUsesField(n.Pipe, field, root) ||
+			nodeUsesField(n.List, field, root) ||
+			nodeUsesField(n.ElseList, field, root)
+	case *parse.WithNode:
+		return nodeUsesField(n.Pipe, field, root) ||
+			nodeUsesField(n.List, field, false) ||
+			nodeUsesField(n.ElseList, field, root)
+	case *parse.RangeNode:
+		return nodeUsesField(n.Pipe, field, root) ||
+			nodeUsesField(n.List, field, false) ||
+			nodeUsesField(n.ElseList, field, root)
+	case *parse.TemplateNode:
+		return nodeUsesField(n.Pipe, field, root)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+
+		for _, c := range n.Cmds {
+			if nodeUsesField(c, field, root) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			if nodeUsesField(a, field, root) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return nodeUsesField(n.Node, field, root)
+	case *parse.FieldNode:
+		return root && n.Ident[0] == field
+	case *parse.VariableNode:
+		return n.Ident[0] == "$" && (len(n.Ident) == 1 || n.Ident[1] == field)
+	case *parse.DotNode:
+		return root
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// jsonEvents parses the JSON lines logged with JSONFormat.
+func jsonEvents(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+
+	var events []map[string]interface{}
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+
+		events = append(events, ev)
+	}
+
+	return events
+}
+
+// jsonEvent returns the first event of the given kind.
+func jsonEvent(t *testing.T, out, kind string) map[string]interface{} {
+	t.Helper()
+
+	for _, ev := range jsonEvents(t, out) {
+		if ev["kind"] == kind {
+			return ev
+		}
+	}
+
+	t.Fatalf("no %s event in:\n%s", kind, out)
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultReservoirSize = 1024
+
+// NewLatencyTracker returns a tracker that records request latency and
+// request/response sizes, keeping the most recent size samples of each.
+func NewLatencyTracker(size int) *LatencyTracker {
+	if size <= 0 {
+		size = defaultReservoirSize
+	}
+
+	return &LatencyTracker{
+		latency:      newReservoir(size),
+		requestSize:  newReservoir(size),
+		responseSize: newReservoir(size),
+	}
+}
+
+// LatencyTracker is responsible for sampling request latency and payload
+// sizes, as observed by a RequestResponseLogger.
+type LatencyTracker struct {
+	latency      *reservoir
+	requestSize  *reservoir
+	responseSize *reservoir
+}
+
+// Observe records the latency and sizes of an exchange. Add it to the
+// Observers of a RequestResponseLogger to sample the requests it serves.
+func (h *LatencyTracker) Observe(o Observation) {
+	h.latency.add(float64(o.Duration) / float64(time.Millisecond))
+	h.requestSize.add(float64(o.RequestBytes))
+	h.responseSize.add(float64(o.ResponseBytes))
+}
+
+// DebugHandler returns an http.Handler reporting the sampled percentiles as
+// JSON at `/debug/latency`.
+func (h *LatencyTracker) DebugHandler() http.Handler {
+	m := http.NewServeMux()
+
+	m.HandleFunc("/latency", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		res := &struct {
+			LatencyMillis reservoirSummary `json:"latency_ms"`
+			RequestBytes  reservoirSummary `json:"request_bytes"`
+			ResponseBytes reservoirSummary `json:"response_bytes"`
+		}{
+			LatencyMillis: h.latency.summary(),
+			RequestBytes:  h.requestSize.summary(),
+			ResponseBytes: h.responseSize.summary(),
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+	})
+
+	return m
+}
+
+// reservoir keeps a bounded ring of the most recent samples.
+type reservoir struct {
+	mu      sync.Mutex
+	samples []float64
+	next    int
+	count   uint64
+}
+
+type reservoirSummary struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+func newReservoir(size int) *reservoir {
+	return &reservoir{samples: make([]float64, 0, size)}
+}
+
+func (r *reservoir) add(v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, v)
+
+		return
+	}
+
+	r.samples[r.next] = v
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+func (r *reservoir) summary() reservoirSummary {
+	r.mu.Lock()
+	sorted := append([]float64(nil), r.samples...)
+	count := r.count
+	r.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return reservoirSummary{Count: count}
+	}
+
+	sort.Float64s(sorted)
+
+	return reservoirSummary{
+		Count: count,
+		P50:   percentile(sorted, 0.50),
+		P90:   percentile(sorted, 0.90),
+		P99:   percentile(sorted, 0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p of the sorted samples.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func latencySummary(t *testing.T, h *LatencyTracker) map[string]reservoirSummary {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/latency", nil))
+
+	var res map[string]reservoirSummary
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestLatencyTrackerObservesLogger(t *testing.T) {
+	events := make(chan LogEvent, 4)
+	tracker := NewLatencyTracker(0)
+
+	l := Logger(MinimalLevel, ioutil.Discard)
+	l.Events = events
+	l.Observers = append(l.Observers, tracker.Observe)
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body) // nolint:errcheck
+		time.Sleep(durationTestSleep)
+		w.Write([]byte("hello")) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("abcdef"))
+	r.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	ev := responseEvent(t, events)
+	res := latencySummary(t, tracker)
+
+	if got := res["latency_ms"]; got.Count != 1 || got.Max != float64(ev.Duration)/float64(time.Millisecond) {
+		t.Errorf("expected the logged duration %v, got %+v", ev.Duration, got)
+	}
+
+	if got := res["request_bytes"].Max; got != 6 {
+		t.Errorf("expected 6 request bytes, got %v", got)
+	}
+
+	if got := res["response_bytes"].Max; got != 5 {
+		t.Errorf("expected 5 response bytes, got %v", got)
+	}
+}
+
+func TestLatencyTrackerReservoir(t *testing.T) {
+	tracker := NewLatencyTracker(2)
+
+	for _, d := range []time.Duration{9, 1, 2} {
+		tracker.Observe(Observation{Duration: d * time.Millisecond})
+	}
+
+	got := latencySummary(t, tracker)["latency_ms"]
+	expected := reservoirSummary{Count: 3, P50: 1, P90: 2, P99: 2, Max: 2}
+
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -2,17 +2,25 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 )
 
 // DetailLevel type.
@@ -29,22 +37,26 @@ const (
 
 // nolint:lll
 const (
-	minimalRequestTemplateDef  = "  (request) {{ with .requestid }}[{{ . }}] {{ end }}{{ .request.Host }} {{ .request.Method }} {{ .request.URL.Path }}\n"
-	minimalResponseTemplateDef = " (response) {{ with .requestid }}[{{ . }}] {{ end }}{{ .response.StatusCode }} {{ status .response.StatusCode }}\n"
-	normalRequestTemplateDef   = minimalRequestTemplateDef + "{{ headers .request.Header }}\n"
-	normalResponseTemplateDef  = minimalResponseTemplateDef + "{{ headers .response.Header }}\n"
-	verboseRequestTemplateDef  = minimalRequestTemplateDef + `---------- BEGIN REQUEST ----------
-{{ dump .request }}
+	requestLineTemplateDef     = "  (request) {{ with .requestid }}[{{ color \"magenta\" . }}] {{ end }}{{ .request.Host }} {{ color \"cyan\" .request.Method }} {{ .request.URL.Path }}{{ with .remainingBudget }} budget={{ . }}{{ end }}{{ with .trace }} trace={{ .TraceID }} span={{ .SpanID }}{{ with .ParentID }} parent={{ . }}{{ end }}{{ end }}"
+	minimalRequestTemplateDef  = requestLineTemplateDef + "\n"
+	clientRequestTemplateDef   = "{{ .timestamp }}" + requestLineTemplateDef + "{{ with .clientIP }} client={{ . }}{{ end }}\n"
+	responseLineTemplateDef    = " (response) {{ with .requestid }}[{{ color \"magenta\" . }}] {{ end }}{{ color (statusColor .response.StatusCode) .response.StatusCode }} {{ status .response.StatusCode }}{{ with .fanout }} fanout={{ . }}{{ end }}{{ with .remainingBudget }} budget={{ . }}{{ end }}"
+	minimalResponseTemplateDef = responseLineTemplateDef + "\n"
+	timedResponseTemplateDef   = "{{ .timestamp }}" + responseLineTemplateDef + "{{ with .duration }} duration={{ . }}{{ end }}\n"
+	normalRequestTemplateDef   = clientRequestTemplateDef + "{{ headers .request.Header }}\n"
+	normalResponseTemplateDef  = timedResponseTemplateDef + "{{ headers .response.Header }}\n"
+	verboseRequestTemplateDef  = clientRequestTemplateDef + `---------- BEGIN REQUEST ----------
+{{ dump .request }}{{ prettyjson .request.Header .body }}
 ----------  END  REQUEST ----------
 `
-	verboseResponseTemplateDef = minimalResponseTemplateDef + `========== BEGIN RESPONSE ==========
+	verboseResponseTemplateDef = timedResponseTemplateDef + `========== BEGIN RESPONSE ==========
 {{ headers .response.Header }}
-{{ if statusBad .response.StatusCode }}{{ .body }}{{ end }}
+{{ if statusBad .response.StatusCode }}{{ prettyjson .response.Header .body }}{{ end }}
 ==========  END  RESPONSE ==========
 `
-	debugResponseTemplateDef = minimalResponseTemplateDef + `========== BEGIN RESPONSE ==========
+	debugResponseTemplateDef = timedResponseTemplateDef + `========== BEGIN RESPONSE ==========
 {{ headers .response.Header }}
-{{ .body }}
+{{ prettyjson .response.Header .body }}
 ==========  END  RESPONSE ==========
 `
 )
@@ -65,19 +77,17 @@ var (
 		"verbose": VerboseLevel,
 		"debug":   DebugLevel,
 	}
-	requestLevelTemplates = map[DetailLevel]*template.Template{
-		NoneLevel:    nil,
-		MinimalLevel: parseTemplate(MinimalLevel, minimalRequestTemplateDef),
-		NormalLevel:  parseTemplate(NormalLevel, normalRequestTemplateDef),
-		VerboseLevel: parseTemplate(VerboseLevel, verboseRequestTemplateDef),
-		DebugLevel:   parseTemplate(DebugLevel, verboseRequestTemplateDef),
+	requestLevelTemplateDefs = map[DetailLevel]string{
+		MinimalLevel: minimalRequestTemplateDef,
+		NormalLevel:  normalRequestTemplateDef,
+		VerboseLevel: verboseRequestTemplateDef,
+		DebugLevel:   verboseRequestTemplateDef,
 	}
-	responseLevelTemplates = map[DetailLevel]*template.Template{
-		NoneLevel:    nil,
-		MinimalLevel: parseTemplate(MinimalLevel, minimalResponseTemplateDef),
-		NormalLevel:  parseTemplate(NormalLevel, normalResponseTemplateDef),
-		VerboseLevel: parseTemplate(VerboseLevel, verboseResponseTemplateDef),
-		DebugLevel:   parseTemplate(DebugLevel, debugResponseTemplateDef),
+	responseLevelTemplateDefs = map[DetailLevel]string{
+		MinimalLevel: minimalResponseTemplateDef,
+		NormalLevel:  normalResponseTemplateDef,
+		VerboseLevel: verboseResponseTemplateDef,
+		DebugLevel:   debugResponseTemplateDef,
 	}
 
 	// RedactedHeaders are the list of headers that are normally redacted.
@@ -87,8 +97,20 @@ var (
 		MinimalLevel: RedactedHeaders,
 		NormalLevel:  RedactedHeaders,
 		VerboseLevel: RedactedHeaders,
-		DebugLevel:   {},
+		DebugLevel:   RedactedHeaders,
 	}
+
+	// RedactedQueryParams are the query parameters whose values are normally
+	// redacted from logged URLs.
+	RedactedQueryParams = []string{"access_token", "api_key", "apikey", "client_secret", "password", "token"}
+
+	// ForwardedHeaders are the headers reverse proxies commonly set to carry
+	// the client address, for use as TrustedProxyHeaders behind proxies that
+	// overwrite or append to them.
+	ForwardedHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+	// RedactedFormFields are the form fields whose values are normally redacted.
+	RedactedFormFields = []string{"password", "passwd", "secret", "token", "access_token", "client_secret"}
 )
 
 // LevelText returns the detail level for the given name.
@@ -100,65 +122,429 @@ func LevelText(level DetailLevel) string {
 	return ""
 }
 
-// TextLevel returns the detail level for the given name.
+// TextLevel returns the detail level for the given name. It delegates to
+// ParseLevel, returning NoneLevel for unknown names.
 func TextLevel(level string) DetailLevel {
-	if l, ok := levels[strings.ToLower(level)]; ok {
-		return l
+	l, _ := ParseLevel(level)
+
+	return l
+}
+
+// ParseLevel returns the detail level for the given name, matched
+// case-insensitively, or an error listing the valid names.
+func ParseLevel(name string) (DetailLevel, error) {
+	if l, ok := levels[strings.ToLower(name)]; ok {
+		return l, nil
 	}
 
-	return NoneLevel
+	return NoneLevel, fmt.Errorf("unknown detail level %q, expected one of %s", name, levelNames())
 }
 
-func parseTemplate(level DetailLevel, def string) *template.Template {
-	name := details[level]
+// levelNames returns the level names in increasing order of detail,
+// separated by "|".
+func levelNames() string {
+	names := make([]string, 0, len(details))
+	for level := NoneLevel; level <= DebugLevel; level++ {
+		names = append(names, details[level])
+	}
 
-	redactedHeaders := func(h http.Header) (orig, redacted http.Header) {
-		orig = h.Clone()
+	return strings.Join(names, "|")
+}
 
-		for _, k := range redactHeaders[level] {
-			if _, ok := h[k]; ok {
-				h[k] = []string{"[redacted]"}
-			}
+// String implements fmt.Stringer.
+func (d DetailLevel) String() string {
+	if name := LevelText(d); len(name) > 0 {
+		return name
+	}
+
+	return fmt.Sprintf("DetailLevel(%d)", int(d))
+}
+
+// Set implements flag.Value, accepting the level names case-insensitively.
+func (d *DetailLevel) Set(name string) error {
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	*d = l
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the level by name.
+func (d DetailLevel) MarshalJSON() ([]byte, error) {
+	name := LevelText(d)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("unknown detail level %d", int(d))
+	}
+
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the level names
+// case-insensitively.
+func (d *DetailLevel) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+
+	return d.Set(name)
+}
+
+// redactHeader returns a copy of h with the headers sensitive at level redacted.
+func (l *coreLogger) redactHeader(level DetailLevel, h http.Header) http.Header {
+	redacted := h.Clone()
+
+	names := redactHeaders[level]
+	if l.RedactHeaders != nil {
+		names = l.RedactHeaders
+	}
+
+	if level == DebugLevel && l.DisableDebugRedaction {
+		names = nil
+	}
+
+	for _, k := range names {
+		k = http.CanonicalHeaderKey(k)
+
+		values, ok := redacted[k]
+		if !ok {
+			continue
 		}
 
-		redacted = h
+		switch {
+		case k == "Set-Cookie":
+			redacted[k] = redactSetCookies(values, l.redact)
 
-		return
+			continue
+		case k == "Cookie" && l.RedactCookieValues:
+			redacted[k] = redactCookies(values, l.RedactCookieNames, l.redact)
+
+			continue
+		}
+
+		redacted[k] = []string{l.redact(k, joinHeaderValues(values))}
+	}
+
+	return redacted
+}
+
+// redact returns the replacement logged for the named sensitive value.
+func (l *coreLogger) redact(name, value string) string {
+	switch {
+	case l.RedactFunc != nil:
+		return l.RedactFunc(name, value)
+	case len(l.RedactPlaceholder) > 0:
+		return l.RedactPlaceholder
+	}
+
+	return defaultRedactPlaceholder
+}
+
+func joinHeaderValues(v []string) string {
+	return strings.Join(v, ",")
+}
+
+func sortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
 	}
 
-	fnMap := map[string]interface{}{
+	sort.Strings(keys)
+
+	return keys
+}
+
+// FuncMap returns the functions available to the templates at level, with
+// any functions added through AddFunc, for use in custom templates passed to
+// SetRequestTemplate and SetResponseTemplate.
+func (l *coreLogger) FuncMap(level DetailLevel) template.FuncMap {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.funcMap(level)
+}
+
+func (l *coreLogger) funcMap(level DetailLevel) template.FuncMap {
+	fnMap := template.FuncMap{
 		"status": http.StatusText,
 		"headers": func(h http.Header) string {
 			var buf bytes.Buffer
-			_, red := redactedHeaders(h)
-			for k, v := range red {
-				fmt.Fprintf(&buf, "%s: %s\n", k, strings.Join(v, ","))
+
+			redacted := l.redactHeader(level, h)
+			for n, k := range sortedHeaderKeys(redacted) {
+				if l.MaxHeadersLogged > 0 && n == l.MaxHeadersLogged {
+					fmt.Fprintf(&buf, "...(%d more headers)\n", len(h)-n)
+
+					break
+				}
+				fmt.Fprintf(&buf, "%s: %s\n", k, joinHeaderValues(redacted[k]))
 			}
 
 			return buf.String()
 		},
-		"requestid": func(h http.Header) string { return h.Get(xRequestIDKey) },
+		"requestid": func(h http.Header) string { return h.Get(l.requestIDHeader()) },
+		"url":       l.redactURL,
 		"dump": func(r *http.Request) string {
-			orig, redacted := redactedHeaders(r.Header)
-			r.Header = redacted
-			b, err := httputil.DumpRequest(r, true)
-			r.Header = orig
+			dr := *r
+			dr.Header = l.redactHeader(level, r.Header)
+			dr.URL = l.redactURL(r.URL)
+			dr.RequestURI = ""
+			b, err := httputil.DumpRequest(&dr, false)
 			if err != nil {
 				return err.Error()
 			}
 
 			return string(b)
 		},
-		"statusGood": func(code int) bool { return http.StatusOK <= code && code < http.StatusBadRequest },
-		"statusBad":  func(code int) bool { return http.StatusBadRequest <= code },
+		"statusGood":  statusGood,
+		"statusBad":   statusBad,
+		"statusColor": statusColor,
+		"color":       l.color,
+		"maskTail":    maskTail,
+		"prettyjson":  prettyJSON,
+	}
+
+	for k, fn := range l.funcs {
+		fnMap[k] = fn
+	}
+
+	return fnMap
+}
+
+func (l *coreLogger) parseTemplate(level DetailLevel, def string) *template.Template {
+	return template.Must(template.New(details[level]).Funcs(l.funcMap(level)).Parse(def))
+}
+
+// SetRequestTemplate replaces the request template for level. The template
+// functions are rebound to this logger, so tmpl may be parsed with the
+// functions from FuncMap. A nil template disables request logging at level.
+func (l *coreLogger) SetRequestTemplate(level DetailLevel, tmpl *template.Template) error {
+	return l.setTemplate(&l.requestOverrides, level, tmpl)
+}
+
+// SetResponseTemplate replaces the response template for level, in the same
+// way as SetRequestTemplate.
+func (l *coreLogger) SetResponseTemplate(level DetailLevel, tmpl *template.Template) error {
+	return l.setTemplate(&l.responseOverrides, level, tmpl)
+}
+
+// nolint:lll
+func (l *coreLogger) setTemplate(overrides *map[DetailLevel]*template.Template, level DetailLevel, tmpl *template.Template) error {
+	if tmpl != nil {
+		t, err := tmpl.Clone()
+		if err != nil {
+			return err
+		}
+
+		tmpl = t
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if *overrides == nil {
+		*overrides = map[DetailLevel]*template.Template{}
+	}
+
+	(*overrides)[level] = tmpl
+	l.templates = nil
+
+	return nil
+}
+
+// overrideTemplate returns a copy of an overriding template bound to the
+// functions for level.
+func (l *coreLogger) overrideTemplate(level DetailLevel, tmpl *template.Template) *template.Template {
+	if tmpl == nil {
+		return nil
+	}
+
+	return template.Must(tmpl.Clone()).Funcs(l.funcMap(level))
+}
+
+// templateSet holds the parsed request and response templates for each level.
+type templateSet struct {
+	request  map[DetailLevel]*template.Template
+	response map[DetailLevel]*template.Template
+	// requestBody records the levels whose request template shows the body.
+	requestBody map[DetailLevel]bool
+}
+
+func (l *coreLogger) newTemplateSet() *templateSet {
+	ts := &templateSet{
+		request:  map[DetailLevel]*template.Template{NoneLevel: nil},
+		response: map[DetailLevel]*template.Template{NoneLevel: nil},
+	}
+
+	for level, def := range requestLevelTemplateDefs {
+		ts.request[level] = l.parseTemplate(level, def)
+	}
+
+	for level, def := range responseLevelTemplateDefs {
+		ts.response[level] = l.parseTemplate(level, def)
+	}
+
+	for level, tmpl := range l.requestOverrides {
+		ts.request[level] = l.overrideTemplate(level, tmpl)
+	}
+
+	for level, tmpl := range l.responseOverrides {
+		ts.response[level] = l.overrideTemplate(level, tmpl)
+	}
+
+	ts.requestBody = map[DetailLevel]bool{}
+	for level, t := range ts.request {
+		ts.requestBody[level] = t != nil && usesField(t, "body")
+	}
+
+	return ts
+}
+
+const defaultRedactPlaceholder = "[redacted]"
+
+// nolint:gochecknoglobals
+var queryValueEscaper = strings.NewReplacer("%", "%25", "&", "%26", "#", "%23", "+", "%2B", " ", "+")
+
+// DefaultMaxBodyBytes is the MaxBodyBytes set by the Logger and
+// NewRoundTripLogger constructors.
+const DefaultMaxBodyBytes = 64 << 10
+
+// Option configures a logger created by Logger or NewRoundTripLogger.
+type Option func(*coreLogger)
+
+// WithMaxBodyBytes sets MaxBodyBytes. Zero logs bodies in full.
+func WithMaxBodyBytes(n int64) Option {
+	return func(l *coreLogger) { l.MaxBodyBytes = n }
+}
+
+// WithRedactedHeaders sets RedactHeaders.
+func WithRedactedHeaders(names ...string) Option {
+	return func(l *coreLogger) { l.RedactHeaders = names }
+}
+
+// WithoutDebugRedaction sets DisableDebugRedaction.
+func WithoutDebugRedaction() Option {
+	return func(l *coreLogger) { l.DisableDebugRedaction = true }
+}
+
+// WithCookieValueRedaction sets RedactCookieValues, redacting the values of
+// the named cookies, or of all cookies when no names are given.
+func WithCookieValueRedaction(names ...string) Option {
+	return func(l *coreLogger) {
+		l.RedactCookieValues = true
+		l.RedactCookieNames = names
+	}
+}
+
+// WithRedactPlaceholder sets RedactPlaceholder.
+func WithRedactPlaceholder(placeholder string) Option {
+	return func(l *coreLogger) { l.RedactPlaceholder = placeholder }
+}
+
+// WithRedactFunc sets RedactFunc.
+func WithRedactFunc(fn func(name, value string) string) Option {
+	return func(l *coreLogger) { l.RedactFunc = fn }
+}
+
+// WithRedactedBodyFields sets RedactBodyFields.
+func WithRedactedBodyFields(paths ...string) Option {
+	return func(l *coreLogger) { l.RedactBodyFields = paths }
+}
+
+// WithTrustedProxies sets TrustedProxies and TrustedProxyHeaders, trusting
+// ForwardedHeaders when no names are given.
+func WithTrustedProxies(proxies int, names ...string) Option {
+	return func(l *coreLogger) {
+		if len(names) == 0 {
+			names = ForwardedHeaders
+		}
+
+		l.TrustedProxies = proxies
+		l.TrustedProxyHeaders = names
+	}
+}
+
+// WithLogRequests sets LogRequests.
+func WithLogRequests(enabled bool) Option {
+	return func(l *coreLogger) { l.LogRequests = enabled }
+}
+
+// WithLogResponses sets LogResponses.
+func WithLogResponses(enabled bool) Option {
+	return func(l *coreLogger) { l.LogResponses = enabled }
+}
+
+// WithTimestampLayout sets TimestampLayout.
+func WithTimestampLayout(layout string) Option {
+	return func(l *coreLogger) { l.TimestampLayout = layout }
+}
+
+// WithColor sets Color.
+func WithColor() Option {
+	return func(l *coreLogger) { l.Color = true }
+}
+
+// WithForcedColor sets Color and ForceColor.
+func WithForcedColor() Option {
+	return func(l *coreLogger) {
+		l.Color = true
+		l.ForceColor = true
 	}
+}
+
+// WithRequestIDHeader sets RequestIDHeader.
+func WithRequestIDHeader(name string) Option {
+	return func(l *coreLogger) { l.RequestIDHeader = name }
+}
+
+// WithFormat sets Format.
+func WithFormat(f Format) Option {
+	return func(l *coreLogger) { l.Format = f }
+}
+
+// WithCorrelation sets Correlate.
+func WithCorrelation() Option {
+	return func(l *coreLogger) { l.Correlate = true }
+}
+
+// WithErrorLevel sets ErrorLevel.
+func WithErrorLevel(level DetailLevel) Option {
+	return func(l *coreLogger) { l.ErrorLevel = level }
+}
+
+// WithSampleRate sets SampleRate.
+func WithSampleRate(rate float64) Option {
+	return func(l *coreLogger) { l.SampleRate = rate }
+}
 
-	return template.Must(template.New(name).Funcs(fnMap).Parse(def))
+// WithFuncs registers template functions as Funcs does. Like
+// template.Funcs, it panics if one of them is invalid.
+func WithFuncs(fns template.FuncMap) Option {
+	return func(l *coreLogger) {
+		if err := l.Funcs(fns); err != nil {
+			panic(err)
+		}
+	}
 }
 
 // Logger returns a logger configured with the given level and output.
-func Logger(level DetailLevel, output io.Writer) *RequestResponseLogger {
-	return &RequestResponseLogger{coreLogger{Level: level, Writer: output}}
+func Logger(level DetailLevel, output io.Writer, opts ...Option) *RequestResponseLogger {
+	l := &RequestResponseLogger{coreLogger: coreLogger{
+		Level:        level,
+		Writer:       output,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+		LogRequests:  true,
+		LogResponses: true,
+	}}
+	for _, opt := range opts {
+		opt(&l.coreLogger)
+	}
+
+	return l
 }
 
 // MinimalLogger returns a logger configured for minimal detail.
@@ -169,10 +555,47 @@ func MinimalLogger(output io.Writer) *RequestResponseLogger {
 // RequestResponseLogger provides detailed HTTP request/response logging.
 type RequestResponseLogger struct {
 	coreLogger
+
+	// ClassLevels overrides the detail level for requests tagged with a
+	// TrafficClass by a TrafficClassifier.
+	ClassLevels map[TrafficClass]DetailLevel
+
+	// ProgressInterval, when non-zero, logs a progress event with the bytes
+	// sent and time elapsed at this interval until the response completes,
+	// followed by a summary event.
+	ProgressInterval time.Duration
+
+	// SkipPaths lists request paths that are passed through without being
+	// logged. Entries ending in a slash match any path with that prefix;
+	// others must match exactly.
+	SkipPaths []string
+
+	// PathLevels overrides the detail level for request paths, matched like
+	// SkipPaths; the longest matching entry wins. A level set on the request
+	// context with WithLogLevel takes precedence, and PathLevels in turn over
+	// ClassLevels.
+	PathLevels map[string]DetailLevel
+
+	// Observers are called with every exchange that is logged, or held back
+	// by SuppressStatuses or ErrorLevel, once its response completes, timed
+	// by the logger. Requests passed through untouched, because of SkipPaths,
+	// SampleRate or NoneLevel, are not observed.
+	Observers []func(Observation)
+}
+
+// Observation describes an exchange completed by a RequestResponseLogger.
+type Observation struct {
+	Method        string
+	Path          string
+	Status        int
+	RequestID     string
+	Start         time.Time
+	Duration      time.Duration
+	RequestBytes  int64
+	ResponseBytes int64
 }
 
-// nolint:interfacer
-func (l *RequestResponseLogger) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+func (l *RequestResponseLogger) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
 	l.Handler(next).ServeHTTP(w, r)
 }
 
@@ -191,16 +614,54 @@ func (l *RequestResponseLogger) Handler(h http.Handler) http.Handler {
 	l.initialize()
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id, _ := GetRequestID(r.Context())
-		switch l.Level {
-		case NoneLevel:
+		if l.skipped(r.URL.Path) || !l.sampled() {
 			h.ServeHTTP(w, r)
 
 			return
+		}
+
+		id, _ := GetRequestID(r.Context())
+		level := l.requestLevel(r)
+
+		switch level {
+		case NoneLevel:
+			if l.ErrorLevel == NoneLevel {
+				h.ServeHTTP(w, r)
+
+				return
+			}
+
+			fallthrough
 		case MinimalLevel, NormalLevel, VerboseLevel, DebugLevel:
-			r := l.logRequest(r, id)
+			if _, ok := GetFanout(r.Context()); !ok {
+				r = r.WithContext(WithFanoutCounter(r.Context()))
+			}
+
+			r = r.WithContext(withLevelOverride(r.Context()))
+
+			if _, ok := GetTraceParent(r.Context()); !ok {
+				if tp, err := ParseTraceParent(r.Header.Get(traceParentHeader)); err == nil {
+					r = r.WithContext(WithTraceParent(r.Context(), tp))
+				}
+			}
+
+			x := &exchange{id: id, level: level, errLevel: l.ErrorLevel, start: time.Now()}
+			if tp, ok := GetTraceParent(r.Context()); ok {
+				x.trace = &tp
+			}
+
+			if len(l.Observers) > 0 && r.Body != nil {
+				x.received = &countingBody{ReadCloser: r.Body}
+				r.Body = x.received
+			}
+
+			r, req := l.logRequest(r, x)
+			if !l.deferRequest() && !req.pending() {
+				l.write(req)
+				req = nil
+			}
 
-			rw, logResponse := l.responseLogger(w, id)
+			rw, logResponse := l.responseLogger(w, r, x, req)
 			defer logResponse()
 
 			h.ServeHTTP(rw, r)
@@ -208,6 +669,103 @@ func (l *RequestResponseLogger) Handler(h http.Handler) http.Handler {
 	})
 }
 
+// Close logs any pending summaries of deduplicated errors, then writes a
+// summary of the requests logged since the logger started: the total, the
+// count per status class, the response bytes served and the uptime.
+func (l *RequestResponseLogger) Close() error {
+	l.initialize()
+	l.closeDedup()
+
+	c := &l.counters
+	fields := []eventField{
+		{"kind", "summary"},
+		{"requests", atomic.LoadUint64(&c.requests)},
+	}
+
+	for class := 1; class < len(c.statusClasses); class++ {
+		fields = append(fields, eventField{fmt.Sprintf("%dxx", class), atomic.LoadUint64(&c.statusClasses[class])})
+	}
+
+	fields = append(fields,
+		eventField{"bytes", atomic.LoadUint64(&c.bytes)},
+		eventField{"uptime", time.Since(l.started).Round(time.Millisecond)},
+	)
+
+	var buf bytes.Buffer
+
+	switch l.Format {
+	case JSONFormat:
+		if err := writeJSONFields(&buf, fields); err != nil {
+			return err
+		}
+	case TextFormat:
+		fmt.Fprintf(&buf, "%11s", "(summary)")
+		writeLogfmtFields(&buf, fields[1:])
+	default:
+		writeLogfmtFields(&buf, fields)
+	}
+
+	return l.writeOut(buf.Bytes())
+}
+
+// skipped reports whether path matches SkipPaths.
+func (l *RequestResponseLogger) skipped(path string) bool {
+	for _, p := range l.SkipPaths {
+		if matchPath(path, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathLevel returns the level of the longest PathLevels entry matching path.
+func (l *RequestResponseLogger) pathLevel(path string) (DetailLevel, bool) {
+	var (
+		level   DetailLevel
+		longest = -1
+	)
+
+	for p, lvl := range l.PathLevels {
+		if len(p) > longest && matchPath(path, p) {
+			level, longest = lvl, len(p)
+		}
+	}
+
+	return level, longest >= 0
+}
+
+// matchPath reports whether path is p, or lies under p when p ends in a slash.
+func matchPath(path, p string) bool {
+	return path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p))
+}
+
+// requestLevel returns the detail level to log r at.
+func (l *RequestResponseLogger) requestLevel(r *http.Request) DetailLevel {
+	if level, ok := GetLogLevel(r.Context()); ok {
+		return level
+	}
+
+	if level, ok := l.pathLevel(r.URL.Path); ok {
+		return level
+	}
+
+	if class, ok := GetTrafficClass(r.Context()); ok {
+		if level, ok := l.ClassLevels[class]; ok {
+			return level
+		}
+	}
+
+	return l.GetLevel()
+}
+
+// CurrentLevel returns the detail level.
+//
+// Deprecated: Use GetLevel.
+func (l *RequestResponseLogger) CurrentLevel() DetailLevel {
+	return l.GetLevel()
+}
+
 func (l *RequestResponseLogger) handleGetLevel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", http.MethodGet)
@@ -220,7 +778,7 @@ func (l *RequestResponseLogger) handleGetLevel(w http.ResponseWriter, r *http.Re
 
 	res := &struct {
 		Level string `json:"level"`
-	}{Level: LevelText(l.Level)}
+	}{Level: LevelText(l.GetLevel())}
 
 	if err := json.NewEncoder(w).Encode(res); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -236,7 +794,7 @@ func (l *RequestResponseLogger) handleLevelChange(w http.ResponseWriter, r *http
 		return
 	}
 
-	if !hasContentType(r.Header, "application/json") {
+	if !HasContentType(r.Header, "application/json") {
 		w.Header().Set("Accept", "application/json")
 		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
 
@@ -245,7 +803,7 @@ func (l *RequestResponseLogger) handleLevelChange(w http.ResponseWriter, r *http
 
 	req := &struct {
 		Level string `json:"level"`
-	}{Level: LevelText(l.Level)}
+	}{Level: LevelText(l.GetLevel())}
 
 	defer r.Body.Close()
 
@@ -255,20 +813,20 @@ func (l *RequestResponseLogger) handleLevelChange(w http.ResponseWriter, r *http
 		return
 	}
 
-	newLevel, ok := levels[req.Level]
-	if !ok {
-		http.Error(w, `expect JSON body like: {"level":"none|minimal|normal|verbose|debug"}`, http.StatusUnprocessableEntity)
+	newLevel, err := ParseLevel(req.Level)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 
 		return
 	}
 
 	switch newLevel {
-	case l.Level:
+	case l.GetLevel():
 		w.WriteHeader(http.StatusAlreadyReported)
 
 		return
 	case NoneLevel, MinimalLevel, NormalLevel, VerboseLevel, DebugLevel:
-		l.Level = newLevel
+		l.SetLevel(newLevel)
 
 		w.WriteHeader(http.StatusAccepted)
 
@@ -280,175 +838,1293 @@ func (l *RequestResponseLogger) handleLevelChange(w http.ResponseWriter, r *http
 	}
 }
 
-func (l *RequestResponseLogger) responseLogger(w http.ResponseWriter, id string) (http.ResponseWriter, func()) {
-	rw := httptest.NewRecorder()
+// responseLogger returns a writer that passes the response straight through
+// to w, so flushes reach the client as they happen, while capturing a copy for
+// the log entry written by the returned func.
+// nolint:lll
+func (l *RequestResponseLogger) responseLogger(w http.ResponseWriter, r *http.Request, x *exchange, req *entry) (http.ResponseWriter, func()) {
+	cw := newCaptureWriter(w, l.MaxBodyBytes)
+	cw.log = l.Log
+	cw.discard = !l.LogResponses
+
+	stop := func() {}
+	if l.ProgressInterval > 0 {
+		stop = l.reportProgress(cw, r, x)
+	}
 
-	return rw, func() {
-		resp := rw.Result()
+	return cw, func() {
+		stop()
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			l.Log.Printf("Error reading body: %v", err)
-		}
+		x.bytes = cw.written()
+		l.finishResponse(cw.result(), r, x, req) // nolint:bodyclose
+	}
+}
 
-		if err = resp.Body.Close(); err != nil {
-			l.Log.Printf("Error closing body: %v", err)
-		}
+func (l *RequestResponseLogger) finishResponse(result *http.Response, r *http.Request, x *exchange, req *entry) {
+	result.Request = r
+	x.duration = time.Since(x.start)
 
-		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
-		defer resp.Body.Close()
+	l.counters.record(result.StatusCode, x.bytes)
+	l.observe(r, result.StatusCode, x)
 
-		for k, v := range resp.Header {
-			w.Header()[k] = v
-		}
+	if l.suppressed(result.StatusCode) {
+		return
+	}
 
-		w.WriteHeader(rw.Code)
-		w.Write(body) // nolint:errcheck
+	x.fanout, _ = GetFanout(r.Context())
 
-		// nolint:bodyclose
-		l.logResponse(rw.Result(), id)
+	if level, ok := GetLogLevel(r.Context()); ok {
+		x.level = level
 	}
-}
 
-// NewRoundTripLogger returns an http.RoundTripper that logs requests and responses.
-// nolint:lll
-func NewRoundTripLogger(inner http.RoundTripper, level DetailLevel, out io.Writer, logger *log.Logger) *RoundTripLogger {
-	l := &RoundTripLogger{
-		coreLogger: coreLogger{
-			Level:  level,
-			Log:    logger,
-			Writer: out,
-		},
-		inner: inner,
+	req = req.resolve()
+
+	if result.StatusCode >= http.StatusBadRequest {
+		req = x.escalate(req)
 	}
-	l.initialize()
 
-	return l
-}
+	res, ok := l.logResponse(result, x)
+	if !ok {
+		return
+	}
 
-// RoundTripLogger is an http.RoundTripper that logs the requests and responses.
-type RoundTripLogger struct {
-	coreLogger
-	inner http.RoundTripper
+	l.write(req, res)
 }
 
-// RoundTrip fulfills the http.RoundTripper interface.
-func (l *RoundTripLogger) RoundTrip(r *http.Request) (*http.Response, error) {
-	id, _ := GetRequestID(r.Context())
+// observe calls the Observers with the completed exchange.
+func (l *RequestResponseLogger) observe(r *http.Request, status int, x *exchange) {
+	if len(l.Observers) == 0 {
+		return
+	}
 
-	l.logRequest(r, id)
+	received := r.ContentLength
+	if x.received != nil && x.received.bytes > received {
+		received = x.received.bytes
+	}
 
-	resp, err := l.inner.RoundTrip(r)
-	if err != nil {
-		return nil, err
+	if received < 0 {
+		received = 0
 	}
 
-	l.logResponse(resp, id)
+	o := Observation{
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Status:        status,
+		RequestID:     x.id,
+		Start:         x.start,
+		Duration:      x.duration,
+		RequestBytes:  received,
+		ResponseBytes: x.bytes,
+	}
 
-	return resp, nil
+	for _, fn := range l.Observers {
+		fn(o)
+	}
+}
+
+// reportProgress logs progress events for the response being written to cw
+// until the returned func is called, which also logs the summary event.
+func (l *RequestResponseLogger) reportProgress(cw *captureWriter, r *http.Request, x *exchange) func() {
+	var (
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+		ticker  = time.NewTicker(l.ProgressInterval)
+	)
+
+	event := func(kind string) *LogEvent {
+		return &LogEvent{
+			Kind:      kind,
+			RequestID: x.id,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Bytes:     cw.written(),
+			Duration:  time.Since(x.start),
+		}
+	}
+
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				l.logEvent(event("progress"))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+
+		l.logEvent(event("stream_summary"))
+	}
+}
+
+// NewRoundTripLogger returns an http.RoundTripper that logs requests and responses.
+// nolint:lll
+func NewRoundTripLogger(inner http.RoundTripper, level DetailLevel, out io.Writer, logger *log.Logger, opts ...Option) *RoundTripLogger {
+	l := &RoundTripLogger{
+		coreLogger: coreLogger{
+			Level:        level,
+			Log:          logger,
+			Writer:       out,
+			MaxBodyBytes: DefaultMaxBodyBytes,
+			LogRequests:  true,
+			LogResponses: true,
+		},
+		inner: inner,
+	}
+	for _, opt := range opts {
+		opt(&l.coreLogger)
+	}
+	l.initialize()
+
+	return l
+}
+
+// RoundTripLogger is an http.RoundTripper that logs the requests and responses.
+type RoundTripLogger struct {
+	coreLogger
+	inner http.RoundTripper
+}
+
+// Close logs any pending summaries of deduplicated errors.
+func (l *RoundTripLogger) Close() error {
+	l.closeDedup()
+
+	return nil
+}
+
+// RoundTrip fulfills the http.RoundTripper interface.
+func (l *RoundTripLogger) RoundTrip(r *http.Request) (*http.Response, error) {
+	id, _ := GetRequestID(r.Context())
+
+	incrementFanout(r.Context())
+
+	if !l.sampled() {
+		return l.inner.RoundTrip(r)
+	}
+
+	x := &exchange{id: id, level: l.GetLevel(), errLevel: l.ErrorLevel, start: time.Now()}
+	x.budget, x.hasBudget = remainingBudget(r.Context())
+
+	if tp, ok := GetTraceParent(r.Context()); ok {
+		child := tp.Child()
+		x.trace = &child
+
+		r = r.Clone(r.Context())
+		r.Header.Set(traceParentHeader, child.String())
+	}
+
+	r, req := l.logRequest(r, x)
+	if !l.deferRequest() && !req.pending() {
+		l.write(req)
+		req = nil
+	}
+
+	resp, err := l.inner.RoundTrip(r)
+	x.duration = time.Since(x.start)
+
+	req = req.resolve()
+
+	if err != nil {
+		req = x.escalate(req)
+		l.write(req, l.logError(r, x, err))
+
+		return nil, err
+	}
+
+	if l.suppressed(resp.StatusCode) {
+		return resp, nil
+	}
+
+	x.budget, x.hasBudget = remainingBudget(r.Context())
+
+	// The body is still to be read by the caller, so only its declared
+	// length is known.
+	if resp.ContentLength > 0 {
+		x.bytes = resp.ContentLength
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		req = x.escalate(req)
+	}
+
+	if res, ok := l.logResponse(resp, x); ok {
+		l.write(req, res)
+	}
+
+	return resp, nil
 }
 
 type coreLogger struct {
+	counters loggerCounters // accessed atomically; kept first for 64-bit alignment
+
 	Level  DetailLevel
 	Log    *log.Logger
 	Writer io.Writer
+	// Format selects the output serialization. Defaults to TextFormat, which
+	// renders the level templates.
+	Format Format
+
+	// Events, when set, receives a copy of every log entry as a LogEvent in
+	// addition to the output written to Writer; set Writer to ioutil.Discard to
+	// deliver to the channel only. Delivery never blocks: events are dropped
+	// when the channel is full.
+	Events chan<- LogEvent
+
+	// BodyContentTypes, when set, lists the media types whose bodies are
+	// logged; other bodies are summarized. When unset, textual bodies are
+	// logged and binary ones summarized, judging by content type and sniffing.
+	BodyContentTypes []string
+
+	// RedactHeaders overrides RedactedHeaders for this logger.
+	RedactHeaders []string
+
+	// DisableDebugRedaction logs headers unredacted at DebugLevel, for deep
+	// debugging. Take care: this logs credentials in the clear.
+	DisableDebugRedaction bool
+
+	// RedactCookieValues redacts the values of individual cookies in the
+	// Cookie header, keeping their names, instead of the whole header.
+	// RedactCookieNames limits this to the named cookies; when empty, every
+	// cookie value is redacted.
+	RedactCookieValues bool
+	RedactCookieNames  []string
+
+	// RedactQueryParams overrides RedactedQueryParams for this logger.
+	RedactQueryParams []string
+
+	// RedactFormFields overrides RedactedFormFields for this logger. Values of
+	// these fields are redacted when logging urlencoded form bodies.
+	RedactFormFields []string
+
+	// RedactPlaceholder replaces redacted header, cookie, query, form and
+	// body values. Defaults to "[redacted]".
+	RedactPlaceholder string
+
+	// RedactFunc, when set, returns the replacement for each redacted value
+	// instead of RedactPlaceholder, given the name of the header, cookie,
+	// parameter or field and its value; for example a hash, to correlate
+	// values without exposing them.
+	RedactFunc func(name, value string) string
+
+	// RedactBodyFields lists the fields of JSON bodies whose values are
+	// redacted in the log, as dotted paths from the top-level object such as
+	// "password" or "user.ssn". Arrays along a path apply it to each element.
+	// JSON bodies that cannot be parsed, such as truncated ones, are
+	// summarized instead when this is set.
+	RedactBodyFields []string
+
+	// RequestIDHeader is the header the requestid template function reads.
+	// Defaults to X-Request-ID.
+	RequestIDHeader string
+
+	// TrustedProxyHeaders lists headers, such as ForwardedHeaders, trusted to
+	// carry the client address. Set it only behind proxies that overwrite or
+	// append to them, since clients can otherwise forge the logged address.
+	// By default only the connection's remote address is used.
+	TrustedProxyHeaders []string
+
+	// TrustedProxies is the number of trusted proxies in front of the server,
+	// which selects the client address in X-Forwarded-For counting from the
+	// right. Defaults to one.
+	TrustedProxies int
+
+	// LogRequests and LogResponses enable logging of each side of the
+	// exchange; a disabled side is neither logged nor buffered. Both are set
+	// by the Logger and NewRoundTripLogger constructors.
+	LogRequests  bool
+	LogResponses bool
+
+	// TimestampLayout is the time layout of the timestamp template value,
+	// the time an entry is logged. Defaults to time.RFC3339.
+	TimestampLayout string
+
+	// Color highlights the method, status code and request ID of the level
+	// templates with ANSI escapes, through the color template function, when
+	// Writer is a terminal. ForceColor does so whatever Writer is.
+	Color      bool
+	ForceColor bool
+
+	// MaxBodyBytes caps how much of each body is held in memory and logged;
+	// longer bodies are logged truncated while still passing through in
+	// full. Zero means unlimited.
+	MaxBodyBytes int64
+
+	// MaxHeadersLogged caps the number of header lines rendered by the headers
+	// template function. Zero means unlimited.
+	MaxHeadersLogged int
+
+	// SuppressStatuses lists response status codes, such as 304 Not Modified,
+	// for which neither the request nor the response is logged. Request
+	// entries are held back until the status is known when this is set.
+	SuppressStatuses []int
+
+	// ErrorLevel, when not NoneLevel, is the detail level for exchanges whose
+	// response status is 400 or above, or that fail outright. Request entries
+	// are held back until the outcome is known when this is set. With Level
+	// set to NoneLevel, only failing exchanges are logged.
+	ErrorLevel DetailLevel
+
+	// SampleRate, when between zero and one, logs only that fraction of
+	// requests, chosen at random; the others pass through untouched. Zero
+	// logs every request.
+	SampleRate float64
+
+	// Correlate holds each request entry back and writes it together with its
+	// response entry, so that the two stay adjacent in the output when
+	// requests are served concurrently.
+	Correlate bool
+
+	// DedupWindow, when non-zero, logs identical error responses (same
+	// route, status and body) once per window, in full, and reports the
+	// occurrences suppressed meanwhile in a "repeated" event with their
+	// count once the window closes, checked every DedupWindow, or on Close.
+	// Request entries are held back until the status is known when this is
+	// set, so that those of suppressed duplicates are dropped too.
+	DedupWindow time.Duration
+	// DedupMaxEntries bounds the number of distinct errors tracked while
+	// deduplicating. Defaults to 1024.
+	DedupMaxEntries int
+
+	dedup errorDedup
+
+	// sink, when set, receives every entry as an event in place of the
+	// output written to Writer.
+	sink func(ev *LogEvent, level DetailLevel)
+
+	settingsMu sync.RWMutex // guards runtime changes to Level and Writer
+	terminal   bool         // whether Writer is a terminal
+	ownLog     bool         // whether Log was created by initialize
+	writeMu    sync.Mutex
+
+	started time.Time
+
+	mu                sync.Mutex
+	funcs             template.FuncMap
+	requestOverrides  map[DetailLevel]*template.Template
+	responseOverrides map[DetailLevel]*template.Template
+	templates         *templateSet
+}
+
+// GetLevel returns the detail level. It is safe to call concurrently with
+// SetLevel.
+func (l *coreLogger) GetLevel() DetailLevel {
+	l.settingsMu.RLock()
+	defer l.settingsMu.RUnlock()
+
+	return l.Level
+}
+
+// SetLevel changes the detail level, safe for use while requests are being
+// logged. Prefer it to assigning Level once the logger is in use.
+func (l *coreLogger) SetLevel(level DetailLevel) {
+	l.settingsMu.Lock()
+	defer l.settingsMu.Unlock()
+
+	l.Level = level
+}
+
+// SetOutput redirects the log entries to w, for example to reopen a log file
+// after rotation, along with the logger's own messages when Log was not
+// provided by the caller. It is safe for use while requests are being logged.
+func (l *coreLogger) SetOutput(w io.Writer) {
+	l.settingsMu.Lock()
+	defer l.settingsMu.Unlock()
+
+	l.Writer = w
+	l.terminal = isTerminal(w)
+
+	if l.ownLog {
+		l.Log.SetOutput(w)
+	}
+}
+
+func (l *coreLogger) output() io.Writer {
+	l.settingsMu.RLock()
+	defer l.settingsMu.RUnlock()
+
+	return l.Writer
+}
+
+// AddFunc registers an additional template function for this logger. Added
+// functions take precedence over the built-in functions of the same name, and
+// the level templates are re-parsed to pick them up.
+func (l *coreLogger) AddFunc(name string, fn interface{}) error {
+	return l.Funcs(template.FuncMap{name: fn})
+}
+
+// Funcs registers the template functions in fns for this logger, as AddFunc
+// does for one. No function is added if any of them is invalid.
+func (l *coreLogger) Funcs(fns template.FuncMap) (err error) {
+	for name, fn := range fns {
+		if err = validateFunc(name, fn); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.funcs == nil {
+		l.funcs = template.FuncMap{}
+	}
+
+	for name, fn := range fns {
+		l.funcs[name] = fn
+	}
+
+	l.templates = nil
+
+	return nil
 }
 
-func (l *coreLogger) logRequest(r *http.Request, id string) *http.Request {
-	if l.Level == NoneLevel {
-		return r
+func validateFunc(name string, fn interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid template func %q: %v", name, r)
+		}
+	}()
+
+	template.New(name).Funcs(template.FuncMap{name: fn})
+
+	return nil
+}
+
+func (l *coreLogger) levelTemplates() *templateSet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.templates == nil {
+		l.templates = l.newTemplateSet()
 	}
 
-	t, ok := requestLevelTemplates[l.Level]
+	return l.templates
+}
+
+// exchange carries the details of a request/response pair that are not part
+// of the response itself.
+type exchange struct {
+	id        string
+	level     DetailLevel
+	errLevel  DetailLevel
+	start     time.Time
+	duration  time.Duration
+	bytes     int64
+	fanout    int64
+	budget    time.Duration
+	hasBudget bool
+	trace     *TraceParent
+	received  *countingBody
+}
+
+// escalate switches the exchange to its error level, returning the request
+// entry rendered for it.
+func (x *exchange) escalate(req *entry) *entry {
+	if x.errLevel == NoneLevel {
+		return req
+	}
+
+	x.level = x.errLevel
+
+	if req == nil {
+		return nil
+	}
+
+	return req.onError
+}
+
+func (x *exchange) data(data map[string]interface{}) map[string]interface{} {
+	if x.hasBudget {
+		data["remainingBudget"] = x.budget
+	}
+
+	if x.trace != nil {
+		data["trace"] = x.trace
+	}
+
+	return data
+}
+
+// remainingBudget returns the time left before the context deadline, which
+// is negative once the deadline has passed, and true if there is a deadline.
+func remainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
 	if !ok {
-		l.Log.Printf("Error missing request template for %v", l.Level)
+		return 0, false
+	}
+
+	return time.Until(deadline), true
+}
 
-		return r
+// entry is a rendered log entry that has not been written yet, so that it can
+// be held back or combined with the entry for the other half of the exchange.
+type entry struct {
+	out   []byte
+	ev    *LogEvent
+	level DetailLevel
+	// onError is the entry to write instead when the request fails, rendered
+	// at ErrorLevel.
+	onError *entry
+	// render, when set, renders the entry once the request body it shows has
+	// been consumed.
+	render func() *entry
+}
+
+// pending reports whether the entry has yet to be rendered.
+func (e *entry) pending() bool {
+	return e != nil && e.render != nil
+}
+
+// resolve returns the rendered entry.
+func (e *entry) resolve() *entry {
+	if !e.pending() {
+		return e
 	}
 
-	if t == nil {
-		return r
+	return e.render()
+}
+
+func (l *coreLogger) logRequest(r *http.Request, x *exchange) (*http.Request, *entry) {
+	if !l.LogRequests || (x.level == NoneLevel && x.errLevel == NoneLevel) {
+		return r, nil
 	}
 
-	var (
-		err  error
-		body = []byte("<nil>")
-	)
+	if r.Body == nil || r.Body == http.NoBody || !l.needsRequestBody(x) {
+		return r, l.renderRequests(r, x, nil, 0)
+	}
 
-	if r.Body != nil {
-		body, err = ioutil.ReadAll(r.Body)
-		if err != nil {
-			l.Log.Printf("Error reading body: %v", err)
+	// The body is captured as it is consumed, so rendering waits until then.
+	tee := &teeBody{ReadCloser: r.Body, limit: l.MaxBodyBytes}
+	r.Body = tee
+
+	return r, &entry{render: func() *entry {
+		body, omitted := tee.logged(r.ContentLength)
 
-			return r
+		return l.renderRequests(r, x, body, omitted)
+	}}
+}
+
+// renderRequests renders the request entry at the exchange level, and at its
+// error level when set.
+func (l *coreLogger) renderRequests(r *http.Request, x *exchange, body []byte, omitted int64) *entry {
+	e := l.renderRequest(r, x, x.level, body, omitted)
+
+	if x.errLevel != NoneLevel {
+		if e == nil {
+			e = &entry{}
 		}
 
-		if err = r.Body.Close(); err != nil {
-			l.Log.Printf("Error closing body: %v", err)
+		e.onError = l.renderRequest(r, x, x.errLevel, body, omitted)
+	}
+
+	return e
+}
+
+// needsRequestBody reports whether the request entries for the exchange show
+// the request body.
+func (l *coreLogger) needsRequestBody(x *exchange) bool {
+	ts := l.levelTemplates()
 
-			return r
+	for _, level := range []DetailLevel{x.level, x.errLevel} {
+		if level == NoneLevel {
+			continue
 		}
 
-		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if level >= VerboseLevel && (l.Events != nil || !l.rendersTemplates()) {
+			return true
+		}
+
+		if l.rendersTemplates() && ts.requestBody[level] {
+			return true
+		}
 	}
 
-	data := map[string]interface{}{
-		"request":   r,
-		"requestid": id,
-		"body":      body,
+	return false
+}
+
+// nolint:lll
+func (l *coreLogger) renderRequest(r *http.Request, x *exchange, level DetailLevel, body []byte, omitted int64) *entry {
+	t, ok := l.levelTemplates().request[level]
+	if !ok {
+		l.Log.Printf("Error missing request template for %v", level)
+
+		return nil
 	}
 
-	if err := t.Execute(l.Writer, data); err != nil {
-		l.Log.Printf("Error executing template %v: %v", l.Level, err)
+	if t == nil {
+		return nil
 	}
 
-	return r
+	var (
+		buf bytes.Buffer
+		ev  = l.requestEvent(r, x, level)
+	)
+
+	if level >= VerboseLevel {
+		ev.Body = l.loggableBody(r.Header, body, omitted)
+	}
+
+	if !l.rendersTemplates() {
+		l.renderEvent(&buf, ev)
+
+		return &entry{out: buf.Bytes(), ev: ev, level: level}
+	}
+
+	data := x.data(map[string]interface{}{
+		"request":    r,
+		"requestid":  x.id,
+		"body":       l.loggableBody(r.Header, body, omitted),
+		"remoteAddr": r.RemoteAddr,
+		"clientIP":   l.clientIP(r),
+		"timestamp":  l.timestamp(),
+	})
+
+	if err := t.Execute(&buf, data); err != nil {
+		l.Log.Printf("Error executing template %v: %v", level, err)
+	}
+
+	return &entry{out: buf.Bytes(), ev: ev, level: level}
 }
 
-func (l *coreLogger) logResponse(r *http.Response, id string) {
-	t, ok := responseLevelTemplates[l.Level]
+// logResponse renders the response entry. It reports false when the whole
+// exchange is to go unlogged, as a suppressed duplicate error.
+func (l *coreLogger) logResponse(r *http.Response, x *exchange) (*entry, bool) {
+	if !l.LogResponses {
+		return nil, true
+	}
+
+	t, ok := l.levelTemplates().response[x.level]
 	if !ok {
-		l.Log.Printf("Error missing response template for %v", l.Level)
+		l.Log.Printf("Error missing response template for %v", x.level)
 
-		return
+		return nil, true
 	}
 
 	if t == nil {
-		return
+		return nil, true
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	var (
+		err     error
+		body    []byte
+		omitted int64
+		buf     bytes.Buffer
+	)
+
+	body, omitted, r.Body, err = readLoggedBody(r.Body, r.ContentLength, l.MaxBodyBytes)
 	if err != nil {
 		l.Log.Printf("Error reading body: %v", err)
 
+		return nil, true
+	}
+
+	logFull, summaries := l.dedupResponse(r, body)
+	for _, ev := range summaries {
+		l.logEvent(ev)
+	}
+
+	if !logFull {
+		return nil, false
+	}
+
+	var ev *LogEvent
+	if l.Events != nil || !l.rendersTemplates() {
+		ev = l.responseEvent(r, x, x.level, l.loggableBody(r.Header, body, omitted))
+	}
+
+	if !l.rendersTemplates() {
+		l.renderEvent(&buf, ev)
+
+		return &entry{out: buf.Bytes(), ev: ev, level: x.level}, true
+	}
+
+	data := x.data(map[string]interface{}{
+		"response":  r,
+		"requestid": x.id,
+		"body":      l.loggableBody(r.Header, body, omitted),
+		"duration":  x.duration,
+		"fanout":    x.fanout,
+		"bytes":     x.bytes,
+		"timestamp": l.timestamp(),
+	})
+
+	if err := t.Execute(&buf, data); err != nil {
+		l.Log.Printf("Error executing template %v: %v", x.level, err)
+	}
+
+	return &entry{out: buf.Bytes(), ev: ev, level: x.level}, true
+}
+
+// logError renders the failure of a request that got no response.
+func (l *coreLogger) logError(r *http.Request, x *exchange, err error) *entry {
+	if x.level == NoneLevel {
+		return nil
+	}
+
+	ev := &LogEvent{
+		Kind:      "error",
+		RequestID: x.id,
+		Host:      r.URL.Host,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Error:     err.Error(),
+		Duration:  x.duration,
+	}
+
+	var buf bytes.Buffer
+
+	l.renderEvent(&buf, ev)
+
+	return &entry{out: buf.Bytes(), ev: ev, level: x.level}
+}
+
+// logEvent writes an event that is not part of a request/response pair, such
+// as a recovered panic. These are logged regardless of level.
+func (l *coreLogger) logEvent(ev *LogEvent) {
+	var buf bytes.Buffer
+
+	l.renderEvent(&buf, ev)
+	l.write(&entry{out: buf.Bytes(), ev: ev})
+}
+
+// write delivers the events of the given entries and writes their output to
+// Writer in a single write.
+func (l *coreLogger) write(entries ...*entry) {
+	var out []byte
+
+	for _, e := range entries {
+		e = e.resolve()
+		if e == nil {
+			continue
+		}
+
+		if e.ev != nil {
+			l.deliver(e.ev)
+
+			if l.sink != nil {
+				l.sink(e.ev, e.level)
+			}
+		}
+
+		out = append(out, e.out...)
+	}
+
+	if len(out) == 0 {
 		return
 	}
 
-	if err = r.Body.Close(); err != nil {
-		l.Log.Printf("Error closing body: %v", err)
+	if err := l.writeOut(out); err != nil {
+		l.Log.Printf("Error writing log entry: %v", err)
+	}
+}
 
+// writeOut writes p to Writer in a single call, serialized with other writes
+// from this logger.
+func (l *coreLogger) writeOut(p []byte) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	_, err := l.output().Write(p)
+
+	return err
+}
+
+// DroppedEvents returns the number of events that could not be delivered to
+// Events because the channel was full.
+func (l *coreLogger) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&l.counters.dropped)
+}
+
+func (l *coreLogger) deliver(ev *LogEvent) {
+	if l.Events == nil {
 		return
 	}
 
-	data := map[string]interface{}{
-		"response":  r,
-		"requestid": id,
-		"body":      string(body),
+	select {
+	case l.Events <- *ev:
+	default:
+		atomic.AddUint64(&l.counters.dropped, 1)
+	}
+}
+
+func (l *coreLogger) renderEvent(w io.Writer, ev *LogEvent) {
+	if l.sink != nil {
+		return
+	}
+
+	var err error
+
+	switch l.Format {
+	case LogfmtFormat:
+		err = ev.writeLogfmt(w)
+	case JSONFormat:
+		err = ev.writeJSON(w)
+	case TextFormat:
+		err = ev.writeText(w)
+	}
+
+	if err != nil {
+		l.Log.Printf("Error writing %s event: %v", ev.Kind, err)
+	}
+}
+
+func (l *coreLogger) requestEvent(r *http.Request, x *exchange, level DetailLevel) *LogEvent {
+	ev := &LogEvent{Kind: "request", RequestID: x.id, Host: r.Host, Method: r.Method, Path: r.URL.Path}
+
+	if x.trace != nil {
+		ev.TraceID, ev.SpanID, ev.ParentSpanID = x.trace.TraceID, x.trace.SpanID, x.trace.ParentID
+	}
+
+	if x.hasBudget {
+		budget := x.budget
+		ev.RemainingBudget = &budget
+	}
+
+	if level >= NormalLevel {
+		ev.ClientIP = l.clientIP(r)
+		ev.Headers = l.redactHeader(level, r.Header)
+	}
+
+	return ev
+}
+
+func (l *coreLogger) requestIDHeader() string {
+	if len(l.RequestIDHeader) == 0 {
+		return xRequestIDKey
+	}
+
+	return l.RequestIDHeader
+}
+
+// clientIP returns the client address of r, trusting TrustedProxyHeaders.
+func (l *coreLogger) clientIP(r *http.Request) string {
+	if len(r.RemoteAddr) == 0 {
+		return ""
+	}
+
+	return clientIP(r, l.TrustedProxyHeaders, l.TrustedProxies)
+}
+
+func (l *coreLogger) responseEvent(r *http.Response, x *exchange, level DetailLevel, body string) *LogEvent {
+	ev := &LogEvent{
+		Kind:      "response",
+		RequestID: x.id,
+		Status:    r.StatusCode,
+		Bytes:     x.bytes,
+		Duration:  x.duration,
+		Fanout:    x.fanout,
+	}
+
+	if x.hasBudget {
+		budget := x.budget
+		ev.RemainingBudget = &budget
+	}
+
+	if level >= NormalLevel {
+		ev.Headers = l.redactHeader(level, r.Header)
+	}
+
+	if level == DebugLevel || (level == VerboseLevel && r.StatusCode >= http.StatusBadRequest) {
+		ev.Body = body
+	}
+
+	return ev
+}
+
+// loggableBody returns the body as it should appear in the log, summarizing
+// bodies that are not to be logged verbatim and marking truncated ones.
+func (l *coreLogger) loggableBody(h http.Header, body []byte, omitted int64) string {
+	body, omitted, ok := l.decodedBody(h, body, omitted)
+	if !ok {
+		return fmt.Sprintf("<undecoded %s body: %d bytes>", h.Get("Content-Encoding"), len(body))
+	}
+
+	s := l.bodyText(h, body)
+
+	switch {
+	case omitted > 0:
+		return fmt.Sprintf("%s… (truncated %d bytes)", s, omitted)
+	case omitted < 0:
+		return s + "… (truncated)"
+	}
+
+	return s
+}
+
+func (l *coreLogger) bodyText(h http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var ok bool
+	if len(l.BodyContentTypes) > 0 {
+		ok = HasContentType(h, l.BodyContentTypes...)
+	} else {
+		ok = isTextual(h, body)
+	}
+
+	if ok && HasContentType(h, "application/x-www-form-urlencoded") {
+		return l.formBody(body)
+	}
+
+	if (ok || len(l.BodyContentTypes) == 0) && HasContentType(h, "multipart/form-data") {
+		return l.multipartBody(h, body)
+	}
+
+	if ok && len(l.RedactBodyFields) > 0 && isJSON(h) {
+		return l.jsonBody(body)
+	}
+
+	if ok {
+		return string(body)
+	}
+
+	ct := h.Get("Content-Type")
+	if len(ct) == 0 {
+		ct = http.DetectContentType(body)
+	}
+
+	return fmt.Sprintf("<binary body: %s, %d bytes>", ct, len(body))
+}
+
+// decodedBody decompresses a copy of a gzip or deflate encoded body for
+// logging, keeping at most MaxBodyBytes of the decompressed content. It reports
+// false for encoded bodies that cannot be decoded, such as those in another
+// encoding or cut short before any content, which are returned as is.
+func (l *coreLogger) decodedBody(h http.Header, body []byte, omitted int64) ([]byte, int64, bool) {
+	encoding := h.Get("Content-Encoding")
+	if len(body) == 0 || len(encoding) == 0 || strings.EqualFold(encoding, "identity") {
+		return body, omitted, true
+	}
+
+	zr, ok, err := decodingReader(encoding, bytes.NewReader(body))
+	if !ok || err != nil {
+		return body, omitted, false
+	}
+	defer zr.Close() // nolint:errcheck
+
+	r := io.Reader(zr)
+	if l.MaxBodyBytes > 0 {
+		r = io.LimitReader(zr, l.MaxBodyBytes+1)
+	}
+
+	decoded, err := ioutil.ReadAll(r)
+
+	switch {
+	case err != nil && len(decoded) == 0:
+		return body, omitted, false
+	case l.MaxBodyBytes > 0 && int64(len(decoded)) > l.MaxBodyBytes:
+		return decoded[:l.MaxBodyBytes], -1, true
+	case err != nil || omitted != 0:
+		return decoded, -1, true
+	}
+
+	return decoded, 0, true
+}
+
+// readLoggedBody reads at most limit bytes of body for logging and returns
+// them along with a body that still yields the full content. omitted is the
+// number of bytes left out, or -1 when more remain but the total is unknown.
+// A limit of zero reads the whole body.
+// nolint:lll
+func readLoggedBody(body io.ReadCloser, contentLength, limit int64) (logged []byte, omitted int64, rest io.ReadCloser, err error) {
+	r := io.Reader(body)
+	if limit > 0 {
+		r = io.LimitReader(body, limit+1)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, body, err
+	}
+
+	if limit <= 0 || int64(len(buf)) <= limit {
+		if err = body.Close(); err != nil {
+			return nil, 0, body, err
+		}
+
+		if int64(len(buf)) == limit && contentLength > limit {
+			omitted = contentLength - limit
+		}
+
+		return buf, omitted, ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}
+
+	omitted = -1
+	if contentLength > limit {
+		omitted = contentLength - limit
+	}
+
+	rest = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), body), Closer: body}
+
+	return buf[:limit], omitted, rest, nil
+}
+
+// formBody renders an urlencoded form one field per line, in the original
+// order, with sensitive values redacted.
+func (l *coreLogger) formBody(body []byte) string {
+	var buf bytes.Buffer
+
+	for _, pair := range strings.Split(string(body), "&") {
+		if len(pair) == 0 {
+			continue
+		}
+
+		k, v := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			k, v = pair[:i], pair[i+1:]
+		}
+
+		if uk, err := url.QueryUnescape(k); err == nil {
+			k = uk
+		}
+
+		if uv, err := url.QueryUnescape(v); err == nil {
+			v = uv
+		}
+
+		fmt.Fprintf(&buf, "%s=%s\n", k, l.redactFormValue(k, v))
+	}
+
+	return buf.String()
+}
+
+// redactFormValue returns v, redacted when k is one of RedactFormFields.
+func (l *coreLogger) redactFormValue(k, v string) string {
+	redact := l.RedactFormFields
+	if redact == nil {
+		redact = RedactedFormFields
+	}
+
+	for _, f := range redact {
+		if strings.EqualFold(f, k) {
+			return l.redact(k, v)
+		}
+	}
+
+	return v
+}
+
+// multipartBody renders a multipart form one field per line, like formBody,
+// with file contents elided and sensitive values redacted. A body cut short
+// by MaxBodyBytes ends with the last field that could be read.
+func (l *coreLogger) multipartBody(h http.Header, body []byte) string {
+	_, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || len(params["boundary"]) == 0 {
+		return fmt.Sprintf("<multipart body: %d bytes>", len(body))
+	}
+
+	var buf bytes.Buffer
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		if name := part.FileName(); len(name) > 0 {
+			n, _ := io.Copy(ioutil.Discard, part)
+			fmt.Fprintf(&buf, "%s=<file: %s, %d bytes>\n", part.FormName(), name, n)
+
+			continue
+		}
+
+		v, _ := ioutil.ReadAll(part)
+		fmt.Fprintf(&buf, "%s=%s\n", part.FormName(), l.redactFormValue(part.FormName(), string(v)))
 	}
 
-	if err := t.Execute(l.Writer, data); err != nil {
-		l.Log.Printf("Error executing template %v: %v", l.Level, err)
+	return buf.String()
+}
+
+// jsonBody renders a JSON body with the values of RedactBodyFields redacted.
+// The body is re-serialized, so object keys come out sorted.
+func (l *coreLogger) jsonBody(body []byte) string {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return fmt.Sprintf("<unparsed JSON body: %d bytes>", len(body))
+	}
+
+	paths := make([][]string, len(l.RedactBodyFields))
+	for i, p := range l.RedactBodyFields {
+		paths[i] = strings.Split(p, ".")
+	}
+
+	var buf bytes.Buffer
+
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+
+	if err := e.Encode(redactJSON(v, paths, l.redact)); err != nil {
+		return fmt.Sprintf("<unparsed JSON body: %d bytes>", len(body))
 	}
 
-	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// redactURL returns a copy of u with the values of sensitive query parameters
+// redacted, leaving the other parameters and their order intact.
+func (l *coreLogger) redactURL(u *url.URL) *url.URL {
+	if u == nil || len(u.RawQuery) == 0 {
+		return u
+	}
+
+	redact := l.RedactQueryParams
+	if redact == nil {
+		redact = RedactedQueryParams
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		raw, v := pair, ""
+		if j := strings.Index(pair, "="); j >= 0 {
+			raw, v = pair[:j], pair[j+1:]
+		}
+
+		k := raw
+		if uk, err := url.QueryUnescape(raw); err == nil {
+			k = uk
+		}
+
+		if uv, err := url.QueryUnescape(v); err == nil {
+			v = uv
+		}
+
+		for _, f := range redact {
+			if strings.EqualFold(f, k) {
+				pairs[i] = raw + "=" + l.redactQueryValue(k, v)
+
+				break
+			}
+		}
+	}
+
+	redacted := *u
+	redacted.RawQuery = strings.Join(pairs, "&")
+
+	return &redacted
+}
+
+// redactQueryValue returns the redacted query value, escaping only what would
+// break up the query so that placeholders such as "[redacted]" stay legible.
+func (l *coreLogger) redactQueryValue(name, value string) string {
+	return queryValueEscaper.Replace(l.redact(name, value))
+}
+
+// dedupResponse reports whether the response should be logged in full, along
+// with summaries of previously suppressed duplicates that are due.
+func (l *coreLogger) dedupResponse(r *http.Response, body []byte) (bool, []*LogEvent) {
+	if l.DedupWindow <= 0 || r.StatusCode < http.StatusBadRequest {
+		return true, nil
+	}
+
+	l.dedup.start(l.DedupWindow, func() { l.flushDedup(false) })
+
+	key, method, path := dedupKey(r, body)
+
+	return l.dedup.observe(key, method, path, r.StatusCode, l.DedupWindow, l.DedupMaxEntries, time.Now())
+}
+
+// flushDedup logs the summaries of suppressed duplicates whose window has
+// closed, or of all of them when all is set.
+func (l *coreLogger) flushDedup(all bool) {
+	for _, ev := range l.dedup.expire(l.DedupWindow, time.Now(), all) {
+		l.logEvent(ev)
+	}
+}
+
+// closeDedup stops the periodic flush of dedup summaries and logs those
+// still pending.
+func (l *coreLogger) closeDedup() {
+	l.dedup.halt()
+	l.flushDedup(true)
+}
+
+func (l *coreLogger) timestamp() string {
+	layout := l.TimestampLayout
+	if len(layout) == 0 {
+		layout = time.RFC3339
+	}
+
+	return time.Now().Format(layout)
+}
+
+// rendersTemplates reports whether entries are rendered with the level
+// templates rather than serialized as events.
+func (l *coreLogger) rendersTemplates() bool {
+	return l.Format == TextFormat && l.sink == nil
+}
+
+// sampled decides whether to log a request, according to SampleRate.
+func (l *coreLogger) sampled() bool {
+	return l.SampleRate <= 0 || l.SampleRate >= 1 || rand.Float64() < l.SampleRate
+}
+
+// deferRequest reports whether request entries must be held back until the
+// response is logged.
+func (l *coreLogger) deferRequest() bool {
+	return l.Correlate || l.ErrorLevel != NoneLevel || len(l.SuppressStatuses) > 0 || l.DedupWindow > 0
+}
+
+func (l *coreLogger) suppressed(status int) bool {
+	for _, s := range l.SuppressStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (l *coreLogger) initialize() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.settingsMu.Lock()
+	defer l.settingsMu.Unlock()
+
+	if l.Writer == nil {
+		l.Writer = os.Stdout
+	}
+
+	l.terminal = isTerminal(l.Writer)
+
 	if l.Log == nil {
 		l.Log = log.New(l.Writer, " [request/response logger] ", log.LstdFlags)
+		l.ownLog = true
 	}
 
-	if l.Writer == nil {
-		l.Writer = os.Stdout
+	if l.started.IsZero() {
+		l.started = time.Now()
+	}
+}
+
+// loggerCounters accumulates totals for the summary written by Close.
+type loggerCounters struct {
+	dropped       uint64
+	requests      uint64
+	bytes         uint64
+	statusClasses [6]uint64
+}
+
+func (c *loggerCounters) record(status int, bytes int64) {
+	atomic.AddUint64(&c.requests, 1)
+
+	if class := status / 100; 0 < class && class < len(c.statusClasses) {
+		atomic.AddUint64(&c.statusClasses[class], 1)
+	}
+
+	if bytes > 0 {
+		atomic.AddUint64(&c.bytes, uint64(bytes))
 	}
 }
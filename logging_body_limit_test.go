@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerMaxBodyBytes(t *testing.T) {
+	var out bytes.Buffer
+
+	reqBody := strings.Repeat("q", 100)
+	respBody := strings.Repeat("r", 100)
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat), WithMaxBodyBytes(10))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil || string(b) != reqBody {
+			t.Errorf("expected the handler to read the full body, got %d bytes, %v", len(b), err)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(respBody)) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+	r.Header.Set("Content-Type", "text/plain")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Body.String() != respBody {
+		t.Errorf("expected the client to receive the full body, got %d bytes", w.Body.Len())
+	}
+
+	if got := jsonEvent(t, out.String(), "request")["body"]; got != "qqqqqqqqqq… (truncated 90 bytes)" {
+		t.Errorf("unexpected request body logged: %q", got)
+	}
+
+	if got := jsonEvent(t, out.String(), "response")["body"]; got != "rrrrrrrrrr… (truncated 90 bytes)" {
+		t.Errorf("unexpected response body logged: %q", got)
+	}
+}
+
+func TestLoggerMaxBodyBytesDefault(t *testing.T) {
+	if l := Logger(MinimalLevel, ioutil.Discard); l.MaxBodyBytes != DefaultMaxBodyBytes {
+		t.Errorf("expected MaxBodyBytes %d, got %d", DefaultMaxBodyBytes, l.MaxBodyBytes)
+	}
+}
+
+func TestRoundTripLoggerMaxBodyBytes(t *testing.T) {
+	respBody := strings.Repeat("r", 100)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(respBody)) // nolint:errcheck
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+
+	client := &http.Client{Transport: NewRoundTripLogger(http.DefaultTransport, DebugLevel, &out, nil,
+		WithFormat(JSONFormat), WithMaxBodyBytes(10))}
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(b) != respBody {
+		t.Errorf("expected the caller to read the full body, got %d bytes, %v", len(b), err)
+	}
+
+	if got := jsonEvent(t, out.String(), "response")["body"]; got != "rrrrrrrrrr… (truncated 90 bytes)" {
+		t.Errorf("unexpected response body logged: %q", got)
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name     string
+		trusted  []string
+		proxies  int
+		headers  map[string]string
+		expected string
+	}{
+		{name: "direct", expected: "10.0.0.1"},
+		{
+			name:     "untrusted by default",
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Real-IP": "203.0.113.8"},
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "forwarded",
+			trusted:  ForwardedHeaders,
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "multiple hops",
+			trusted:  ForwardedHeaders,
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7, 198.51.100.2, 10.0.0.9"},
+			expected: "10.0.0.9",
+		},
+		{
+			name:     "multiple hops behind two proxies",
+			trusted:  ForwardedHeaders,
+			proxies:  2,
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7, 198.51.100.2, 10.0.0.9"},
+			expected: "198.51.100.2",
+		},
+		{
+			name:     "fewer hops than proxies",
+			trusted:  ForwardedHeaders,
+			proxies:  2,
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "real ip",
+			trusted:  ForwardedHeaders,
+			headers:  map[string]string{"X-Real-IP": "203.0.113.8"},
+			expected: "203.0.113.8",
+		},
+		{
+			name:     "invalid forwarded",
+			trusted:  ForwardedHeaders,
+			headers:  map[string]string{"X-Forwarded-For": "unknown"},
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "custom trusted",
+			trusted:  []string{"CF-Connecting-IP"},
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7", "CF-Connecting-IP": "203.0.113.9"},
+			expected: "203.0.113.9",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:4321"
+
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+
+			l := &coreLogger{TrustedProxyHeaders: c.trusted, TrustedProxies: c.proxies}
+			if got := l.clientIP(r); got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggerClientIPTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{name: "spoofed header ignored", expected: "client=10.0.0.1"},
+		{name: "trusted proxy", opts: []Option{WithTrustedProxies(1)}, expected: "client=203.0.113.7"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			h := Logger(NormalLevel, &out, c.opts...).Handler(http.NotFoundHandler())
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:4321"
+			r.Header.Set("X-Forwarded-For", "198.51.100.66, 203.0.113.7")
+
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !strings.Contains(out.String(), c.expected) {
+				t.Errorf("expected %q in the normal template, got:\n%s", c.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestLoggerClientIPEvent(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(NormalLevel, &out, WithFormat(JSONFormat)).Handler(http.NotFoundHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:4321"
+	r.Header.Set("X-Forwarded-For", "198.51.100.66")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := jsonEvent(t, out.String(), "request")["client_ip"]; got != "10.0.0.1" {
+		t.Errorf("expected a client-supplied X-Forwarded-For ignored, got %v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func coloredOutput(status int, opts ...Option) string {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, opts...)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return out.String()
+}
+
+func TestLoggerColorDisabled(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "not a terminal", opts: []Option{WithColor()}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := coloredOutput(http.StatusOK, c.opts...); strings.Contains(got, "\x1b[") {
+				t.Errorf("expected no escapes, got %q", got)
+			}
+		})
+	}
+}
+
+func TestLoggerForcedColor(t *testing.T) {
+	cases := []struct {
+		status   int
+		expected string
+	}{
+		{status: http.StatusOK, expected: "\x1b[32m200\x1b[0m"},
+		{status: http.StatusNotFound, expected: "\x1b[33m404\x1b[0m"},
+		{status: http.StatusBadGateway, expected: "\x1b[31m502\x1b[0m"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(http.StatusText(c.status), func(t *testing.T) {
+			if got := coloredOutput(c.status, WithForcedColor()); !strings.Contains(got, c.expected) {
+				t.Errorf("expected %q in %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggerColorFunc(t *testing.T) {
+	l := &coreLogger{Color: true, ForceColor: true}
+
+	if got := l.color("cyan", "GET"); got != "\x1b[36mGET\x1b[0m" {
+		t.Errorf("expected a cyan value, got %q", got)
+	}
+
+	if got := l.color("", 200); got != "200" {
+		t.Errorf("expected an unknown color to leave the value plain, got %q", got)
+	}
+
+	l.Color = false
+	if got := l.color("cyan", "GET"); got != "GET" {
+		t.Errorf("expected no escapes when disabled, got %q", got)
+	}
+}
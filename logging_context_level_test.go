@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetLogLevel(t *testing.T) {
+	if _, ok := GetLogLevel(context.Background()); ok {
+		t.Error("expected no override")
+	}
+
+	if level, ok := GetLogLevel(WithLogLevel(context.Background(), DebugLevel)); !ok || level != DebugLevel {
+		t.Errorf("expected %v, got %v, %t", DebugLevel, level, ok)
+	}
+
+	if _, ok := GetLogLevel(withLevelOverride(context.Background())); ok {
+		t.Error("expected an unset override to report none")
+	}
+}
+
+func TestLoggerContextLevelUpstream(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out)
+	h := l.Handler(http.NotFoundHandler())
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Debug") == "1" {
+			r = r.WithContext(WithLogLevel(r.Context(), VerboseLevel))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+
+	upstream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/quiet", nil))
+
+	if strings.Contains(out.String(), "BEGIN REQUEST") {
+		t.Errorf("expected minimal output without an override, got:\n%s", out.String())
+	}
+
+	out.Reset()
+
+	r := httptest.NewRequest(http.MethodGet, "/loud", nil)
+	r.Header.Set("X-Debug", "1")
+	upstream.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(out.String(), "BEGIN REQUEST") || !strings.Contains(out.String(), "BEGIN RESPONSE") {
+		t.Errorf("expected verbose output with an override, got:\n%s", out.String())
+	}
+
+	if got := l.GetLevel(); got != MinimalLevel {
+		t.Errorf("expected the logger level unchanged, got %v", got)
+	}
+}
+
+func TestLoggerContextLevelDownstream(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(MinimalLevel, &out).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WithLogLevel(r.Context(), VerboseLevel)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if strings.Contains(out.String(), "BEGIN REQUEST") || !strings.Contains(out.String(), "BEGIN RESPONSE") {
+		t.Errorf("expected a minimal request and a verbose response, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func loggedCookieHeader(t *testing.T, opts ...Option) interface{} {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	l := Logger(NormalLevel, &out, append([]Option{WithFormat(JSONFormat)}, opts...)...)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Cookie", "session=s3cr3t; theme=dark; csrf=tok")
+
+	l.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+
+	headers, _ := jsonEvent(t, out.String(), "request")["headers"].(map[string]interface{})
+
+	return headerValue(headers, "Cookie")
+}
+
+func TestLoggerCookieValueRedaction(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{name: "whole header", expected: "[redacted]"},
+		{
+			name:     "all values",
+			opts:     []Option{WithCookieValueRedaction()},
+			expected: "session=[redacted]; theme=[redacted]; csrf=[redacted]",
+		},
+		{
+			name:     "named values",
+			opts:     []Option{WithCookieValueRedaction("session", "csrf")},
+			expected: "session=[redacted]; theme=dark; csrf=[redacted]",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := loggedCookieHeader(t, c.opts...); got != c.expected {
+				t.Errorf("expected %q, got %v", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerCorrelate(t *testing.T) {
+	const n = 50
+
+	var out syncBuffer
+
+	l := Logger(NormalLevel, &out, WithFormat(JSONFormat), WithCorrelation())
+	h := NewRequestIDHandler(nil).Handler(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.Write([]byte(r.URL.Path)) // nolint:errcheck
+	})))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%d", i), nil))
+		}(i)
+	}
+
+	wg.Wait()
+
+	events := jsonEvents(t, out.String())
+	if len(events) != 2*n {
+		t.Fatalf("expected %d events, got %d", 2*n, len(events))
+	}
+
+	for i := 0; i < len(events); i += 2 {
+		req, resp := events[i], events[i+1]
+
+		if req["kind"] != "request" || resp["kind"] != "response" {
+			t.Fatalf("expected a request followed by its response, got %v then %v", req["kind"], resp["kind"])
+		}
+
+		if id := req["request_id"]; id == nil || id != resp["request_id"] {
+			t.Errorf("expected matching request IDs, got %v and %v", id, resp["request_id"])
+		}
+	}
+}
+
+func TestLoggerCorrelateSingleWrite(t *testing.T) {
+	var out countingWriter
+
+	l := Logger(NormalLevel, &out, WithCorrelation())
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if out.writes != 1 {
+		t.Errorf("expected the request and response in one write, got %d writes", out.writes)
+	}
+}
+
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+
+	return len(p), nil
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func loggedDebugHeaders(t *testing.T, opts ...Option) map[string]interface{} {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, append([]Option{WithFormat(JSONFormat)}, opts...)...)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=abc")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	headers, _ := jsonEvent(t, out.String(), "request")["headers"].(map[string]interface{})
+
+	return headers
+}
+
+func TestLoggerDebugRedactsByDefault(t *testing.T) {
+	headers := loggedDebugHeaders(t)
+
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if got := headerValue(headers, name); got != "[redacted]" {
+			t.Errorf("expected %s redacted at debug level, got %v", name, got)
+		}
+	}
+}
+
+func TestLoggerWithoutDebugRedaction(t *testing.T) {
+	headers := loggedDebugHeaders(t, WithoutDebugRedaction())
+
+	if got := headerValue(headers, "Authorization"); got != "Bearer secret" {
+		t.Errorf("expected Authorization logged, got %v", got)
+	}
+
+	if got := headerValue(headers, "Cookie"); got != "session=abc" {
+		t.Errorf("expected Cookie logged, got %v", got)
+	}
+}
+
+func TestLoggerWithoutDebugRedactionOtherLevels(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(VerboseLevel, &out, WithFormat(JSONFormat), WithoutDebugRedaction())
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	headers, _ := jsonEvent(t, out.String(), "request")["headers"].(map[string]interface{})
+	if got := headerValue(headers, "Authorization"); got != "[redacted]" {
+		t.Errorf("expected Authorization still redacted below debug level, got %v", got)
+	}
+}
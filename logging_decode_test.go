@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, encoding, s string) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		zw  io.WriteCloser
+	)
+
+	switch encoding {
+	case "gzip":
+		zw = gzip.NewWriter(&buf)
+	case "deflate":
+		zw = zlib.NewWriter(&buf)
+	}
+
+	if _, err := io.WriteString(zw, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// loggedResponseBody serves body with the given encoding through a debug
+// level JSON logger, returning the response and the body field logged for it.
+func loggedResponseBody(t *testing.T, encoding string, body []byte, opts ...Option) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, append([]Option{WithFormat(JSONFormat)}, opts...)...)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body) // nolint:errcheck
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var ev struct {
+			Kind string `json:"kind"`
+			Body string `json:"body"`
+		}
+
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+
+		if ev.Kind == "response" {
+			return w, ev.Body
+		}
+	}
+
+	t.Fatalf("no response logged:\n%s", out.String())
+
+	return nil, ""
+}
+
+func TestLoggerDecodesBodies(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		encoded := compress(t, encoding, `{"name":"gopher"}`)
+
+		w, logged := loggedResponseBody(t, encoding, encoded)
+
+		if logged != `{"name":"gopher"}` {
+			t.Errorf("%s: expected the decoded JSON logged, got %q", encoding, logged)
+		}
+
+		if !bytes.Equal(w.Body.Bytes(), encoded) {
+			t.Errorf("%s: expected the client to receive the encoded body", encoding)
+		}
+	}
+}
+
+func TestLoggerDecodesTruncatedBodies(t *testing.T) {
+	var values []string
+	for i := 0; i < 1000; i++ {
+		values = append(values, strconv.Itoa(i*i))
+	}
+
+	encoded := compress(t, "gzip", `{"values":[`+strings.Join(values, ",")+`]}`)
+
+	_, logged := loggedResponseBody(t, "gzip", encoded, WithMaxBodyBytes(64))
+
+	if !strings.HasPrefix(logged, `{"values":[0,1`) || !strings.HasSuffix(logged, "… (truncated)") {
+		t.Errorf("expected the decoded prefix logged, got %q", logged)
+	}
+}
+
+func TestLoggerOmitsUndecodableBodies(t *testing.T) {
+	encoded := compress(t, "gzip", `{"name":"gopher"}`)
+
+	// Too little of the body is kept to decode any of it.
+	_, logged := loggedResponseBody(t, "gzip", encoded, WithMaxBodyBytes(4))
+	if !strings.HasPrefix(logged, "<undecoded gzip body: 4 bytes>") {
+		t.Errorf("expected a placeholder for a truncated body, got %q", logged)
+	}
+
+	_, logged = loggedResponseBody(t, "br", []byte("\x8b\x03\x80compressed"))
+	if logged != "<undecoded br body: 13 bytes>" {
+		t.Errorf("expected a placeholder for an unsupported encoding, got %q", logged)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const durationTestSleep = 20 * time.Millisecond
+
+func responseEvent(t *testing.T, events <-chan LogEvent) LogEvent {
+	t.Helper()
+
+	for {
+		select {
+		case ev := <-events:
+			if ev.Kind == "response" {
+				return ev
+			}
+		default:
+			t.Fatal("no response event")
+		}
+	}
+}
+
+func TestLoggerDuration(t *testing.T) {
+	events := make(chan LogEvent, 4)
+
+	l := Logger(MinimalLevel, ioutil.Discard)
+	l.Events = events
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(durationTestSleep)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := responseEvent(t, events).Duration; got < durationTestSleep {
+		t.Errorf("expected a duration of at least %v, got %v", durationTestSleep, got)
+	}
+}
+
+func TestLoggerDurationInTemplate(t *testing.T) {
+	for _, level := range []DetailLevel{NormalLevel, VerboseLevel} {
+		var out bytes.Buffer
+
+		h := Logger(level, &out).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if !strings.Contains(out.String(), " duration=") {
+			t.Errorf("expected a duration at %v, got:\n%s", level, out.String())
+		}
+	}
+}
+
+func TestRoundTripLoggerDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(durationTestSleep)
+	}))
+	defer ts.Close()
+
+	events := make(chan LogEvent, 4)
+
+	rt := NewRoundTripLogger(http.DefaultTransport, MinimalLevel, ioutil.Discard, nil)
+	rt.Events = events
+
+	resp, err := (&http.Client{Transport: rt}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if got := responseEvent(t, events).Duration; got < durationTestSleep {
+		t.Errorf("expected a duration of at least %v, got %v", durationTestSleep, got)
+	}
+}
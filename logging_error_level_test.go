@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func errorLevelOutput(level DetailLevel, status int) string {
+	var out bytes.Buffer
+
+	h := Logger(level, &out, WithErrorLevel(VerboseLevel)).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "outcome", status)
+		}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return out.String()
+}
+
+func TestLoggerErrorLevel(t *testing.T) {
+	ok := errorLevelOutput(MinimalLevel, http.StatusOK)
+	if strings.Contains(ok, "BEGIN REQUEST") || !strings.Contains(ok, "(request) example.com GET /") {
+		t.Errorf("expected a minimal entry for a 200, got:\n%s", ok)
+	}
+
+	failed := errorLevelOutput(MinimalLevel, http.StatusInternalServerError)
+	for _, s := range []string{"BEGIN REQUEST", "GET / HTTP/1.1", "BEGIN RESPONSE", "outcome"} {
+		if !strings.Contains(failed, s) {
+			t.Errorf("expected a full dump containing %q for a 500, got:\n%s", s, failed)
+		}
+	}
+}
+
+func TestLoggerErrorLevelOnly(t *testing.T) {
+	if got := errorLevelOutput(NoneLevel, http.StatusOK); len(got) > 0 {
+		t.Errorf("expected nothing logged for a 200, got:\n%s", got)
+	}
+
+	if got := errorLevelOutput(NoneLevel, http.StatusNotFound); !strings.Contains(got, "BEGIN REQUEST") {
+		t.Errorf("expected a full dump for a 404, got:\n%s", got)
+	}
+}
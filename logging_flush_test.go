@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoggerPassesFlushesThrough(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	l := Logger(DebugLevel, ioutil.Discard)
+	ts := httptest.NewServer(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first\n")) // nolint:errcheck
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+
+		w.Write([]byte("second\n")) // nolint:errcheck
+		close(done)
+	})))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+		t.Error("expected the first chunk before the handler completed")
+	default:
+	}
+
+	if line != "first\n" {
+		t.Errorf("expected the first chunk, got %q", line)
+	}
+
+	close(release)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func upperRequestTemplate(l *RequestResponseLogger) *template.Template {
+	return template.Must(template.New("req").Funcs(l.FuncMap(MinimalLevel)).Parse(
+		"{{ upper .request.URL.Path }}\n"))
+}
+
+func TestLoggerFuncs(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithLogResponses(false), WithFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	if err := l.SetRequestTemplate(MinimalLevel, upperRequestTemplate(l)); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/path", nil))
+
+	if got := out.String(); got != "/PATH\n" {
+		t.Errorf("expected %q, got %q", "/PATH\n", got)
+	}
+}
+
+func TestLoggerAddFuncAfterSetTemplate(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithLogResponses(false))
+	if err := l.AddFunc("upper", func(s string) string { return s }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.SetRequestTemplate(MinimalLevel, upperRequestTemplate(l)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.AddFunc("upper", strings.ToUpper); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/path", nil))
+
+	if got := out.String(); got != "/PATH\n" {
+		t.Errorf("expected the latest func to be used, got %q", got)
+	}
+}
+
+func TestLoggerFuncsOverrideBuiltin(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithLogRequests(false),
+		WithFuncs(template.FuncMap{"status": func(int) string { return "custom" }}))
+
+	resp := template.Must(template.New("resp").Funcs(l.FuncMap(MinimalLevel)).Parse(
+		"{{ status .response.StatusCode }}\n"))
+	if err := l.SetResponseTemplate(MinimalLevel, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := out.String(); got != "custom\n" {
+		t.Errorf("expected the user func to take precedence, got %q", got)
+	}
+}
+
+func TestLoggerFuncsInvalid(t *testing.T) {
+	l := Logger(MinimalLevel, &bytes.Buffer{})
+
+	if err := l.Funcs(template.FuncMap{"upper": strings.ToUpper, "bad": 42}); err == nil {
+		t.Error("expected an error for an invalid func")
+	}
+
+	if _, ok := l.FuncMap(MinimalLevel)["upper"]; ok {
+		t.Error("expected no func to be added")
+	}
+}
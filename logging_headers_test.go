@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeadersFuncSorted(t *testing.T) {
+	h := http.Header{}
+	for _, k := range []string{"X-Zeta", "Accept", "X-Alpha", "Content-Type", "User-Agent"} {
+		h.Set(k, "v")
+	}
+
+	h.Add("Accept", "w")
+
+	headers := (&coreLogger{}).funcMap(NormalLevel)["headers"].(func(http.Header) string)
+
+	expected := "Accept: v,w\nContent-Type: v\nUser-Agent: v\nX-Alpha: v\nX-Zeta: v\n"
+	for i := 0; i < 20; i++ {
+		if got := headers(h); got != expected {
+			t.Fatalf("expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestHeadersFuncMaxHeadersLogged(t *testing.T) {
+	h := http.Header{"B": {"2"}, "A": {"1"}, "C": {"3"}}
+
+	headers := (&coreLogger{MaxHeadersLogged: 2}).funcMap(NormalLevel)["headers"].(func(http.Header) string)
+
+	if got, expected := headers(h), "A: 1\nB: 2\n...(1 more headers)\n"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
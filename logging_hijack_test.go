@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerHijack(t *testing.T) {
+	var out syncBuffer
+
+	l := Logger(VerboseLevel, &out)
+	ts := httptest.NewServer(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+
+			return
+		}
+		defer conn.Close()
+
+		if conn.RemoteAddr() == nil {
+			t.Error("expected a connected net.Conn")
+		}
+
+		// nolint:errcheck
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush() // nolint:errcheck
+
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line) // nolint:errcheck
+		rw.Flush()           // nolint:errcheck
+	})))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")) // nolint:errcheck
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	conn.Write([]byte("ping\n")) // nolint:errcheck
+
+	if line, err := br.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("expected the echo over the hijacked connection, got %q, %v", line, err)
+	}
+
+	ts.Close()
+
+	if !strings.Contains(out.String(), "101 Switching Protocols") {
+		t.Errorf("expected the upgrade logged:\n%s", out.String())
+	}
+}
+
+func TestLoggerHijackUnsupported(t *testing.T) {
+	var out syncBuffer
+
+	l := Logger(MinimalLevel, &out)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+			t.Errorf("expected http.ErrNotSupported, got %v", err)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
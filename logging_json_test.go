@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type jsonLine struct {
+	Kind      string              `json:"kind"`
+	RequestID string              `json:"request_id"`
+	Host      string              `json:"host"`
+	Method    string              `json:"method"`
+	Path      string              `json:"path"`
+	Status    int                 `json:"status"`
+	Headers   map[string][]string `json:"headers"`
+	Body      string              `json:"body"`
+	Duration  string              `json:"duration"`
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat))
+	h := NewRequestIDHandler(nil).Handler(l.Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body) // nolint:errcheck
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created \"quoted\"\n")) // nolint:errcheck
+		})))
+
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/things", strings.NewReader("thing"))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set(xRequestIDKey, "req-1")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var lines []jsonLine
+
+	s := bufio.NewScanner(&out)
+	for s.Scan() {
+		var line jsonLine
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", s.Text(), err)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	req, resp := lines[0], lines[1]
+
+	if req.Kind != "request" || req.RequestID != "req-1" || req.Host != "example.com" ||
+		req.Method != http.MethodPost || req.Path != "/things" || req.Body != "thing" {
+		t.Errorf("unexpected request line: %+v", req)
+	}
+
+	if got := req.Headers["Authorization"]; len(got) != 1 || got[0] != "[redacted]" {
+		t.Errorf("expected Authorization redacted, got %v", got)
+	}
+
+	if resp.Kind != "response" || resp.RequestID != "req-1" || resp.Status != http.StatusCreated ||
+		resp.Body != "created \"quoted\"\n" || len(resp.Duration) == 0 {
+		t.Errorf("unexpected response line: %+v", resp)
+	}
+}
+
+func TestLoggerJSONFormatMinimal(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(MinimalLevel, &out, WithFormat(JSONFormat)).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("body")) // nolint:errcheck
+		}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Custom", "value")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, ev := range jsonEvents(t, out.String()) {
+		if _, ok := ev["headers"]; ok {
+			t.Errorf("expected no headers at minimal level, got %v", ev)
+		}
+
+		if _, ok := ev["body"]; ok {
+			t.Errorf("expected no body at minimal level, got %v", ev)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerMinimalLevelLeavesBodyAlone(t *testing.T) {
+	for _, level := range []DetailLevel{MinimalLevel, NormalLevel} {
+		body := ioutil.NopCloser(strings.NewReader("payload"))
+
+		h := Logger(level, ioutil.Discard).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != body {
+				t.Errorf("expected the original body at %v, got %T", level, r.Body)
+			}
+		}))
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Body = body
+
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+func TestLoggerVerboseLevelCapturesBodyAsRead(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(VerboseLevel, &out).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Body.(*teeBody); !ok {
+			t.Errorf("expected the body to be captured as read, got %T", r.Body)
+		}
+
+		io.Copy(ioutil.Discard, r.Body) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	r.Header.Set("Content-Type", "text/plain")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(out.String(), "\npayload\n") {
+		t.Errorf("expected the body in the log, got:\n%s", out.String())
+	}
+}
+
+func BenchmarkLoggerMinimalLevelBody(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), 1<<20)
+
+	h := Logger(MinimalLevel, ioutil.Discard).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body) // nolint:errcheck
+	}))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+var _ flag.Value = new(DetailLevel)
+
+func TestDetailLevelString(t *testing.T) {
+	for level := NoneLevel; level <= DebugLevel; level++ {
+		var parsed DetailLevel
+		if err := parsed.Set(level.String()); err != nil || parsed != level {
+			t.Errorf("expected %v to round-trip, got %v, %v", level, parsed, err)
+		}
+	}
+
+	if got := fmt.Sprint(DetailLevel(42)); got != "DetailLevel(42)" {
+		t.Errorf("unexpected string for an unknown level: %q", got)
+	}
+}
+
+func TestDetailLevelFlag(t *testing.T) {
+	level := MinimalLevel
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&level, "level", "log detail level")
+
+	if err := fs.Parse([]string{"-level", "VERBOSE"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if level != VerboseLevel {
+		t.Errorf("expected %v, got %v", VerboseLevel, level)
+	}
+
+	if err := fs.Parse([]string{"-level", "garbage"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+
+	if level != VerboseLevel {
+		t.Errorf("expected the level unchanged after an error, got %v", level)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDetailLevelJSON(t *testing.T) {
+	type config struct {
+		Level DetailLevel `json:"level"`
+	}
+
+	b, err := json.Marshal(config{Level: VerboseLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != `{"level":"verbose"}` {
+		t.Errorf("unexpected JSON %s", b)
+	}
+
+	var c config
+	if err := json.Unmarshal([]byte(`{"level":"Debug"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Level != DebugLevel {
+		t.Errorf("expected %v, got %v", DebugLevel, c.Level)
+	}
+}
+
+func TestDetailLevelJSONInvalid(t *testing.T) {
+	if _, err := json.Marshal(DetailLevel(42)); err == nil {
+		t.Error("expected an error marshaling an unknown level")
+	}
+
+	for _, in := range []string{`"garbage"`, `3`} {
+		var level DetailLevel
+		if err := json.Unmarshal([]byte(in), &level); err == nil {
+			t.Errorf("expected an error unmarshaling %s", in)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]DetailLevel{"none": NoneLevel, "Normal": NormalLevel, "DEBUG": DebugLevel} {
+		if got, err := ParseLevel(name); err != nil || got != expected {
+			t.Errorf("expected %q to parse as %v, got %v, %v", name, expected, got, err)
+		}
+	}
+}
+
+func TestParseLevelError(t *testing.T) {
+	_, err := ParseLevel("verbos")
+	if err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+
+	for _, name := range []string{`"verbos"`, "none|minimal|normal|verbose|debug"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected the error to mention %s, got %q", name, err)
+		}
+	}
+
+	if got := TextLevel("verbos"); got != NoneLevel {
+		t.Errorf("expected TextLevel to default to %v, got %v", NoneLevel, got)
+	}
+}
+
+func TestLevelHandlerRejectsUnknownLevel(t *testing.T) {
+	l := Logger(NormalLevel, ioutil.Discard)
+
+	r := httptest.NewRequest(http.MethodPut, "/set", strings.NewReader(`{"level":"verbos"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	l.LevelHandler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "none|minimal|normal|verbose|debug") {
+		t.Errorf("expected the valid levels in the response, got %q", w.Body.String())
+	}
+
+	if got := l.GetLevel(); got != NormalLevel {
+		t.Errorf("expected the level unchanged, got %v", got)
+	}
+}
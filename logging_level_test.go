@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestLoggerSetLevelConcurrently(t *testing.T) {
+	l := Logger(MinimalLevel, ioutil.Discard)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				l.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), next)
+			}
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				l.SetLevel(DetailLevel((i + j) % int(DebugLevel+1)))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestLoggerGetLevel(t *testing.T) {
+	l := Logger(MinimalLevel, ioutil.Discard)
+
+	l.SetLevel(VerboseLevel)
+
+	if got := l.GetLevel(); got != VerboseLevel {
+		t.Errorf("expected %v, got %v", VerboseLevel, got)
+	}
+}
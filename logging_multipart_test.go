@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerMultipartBody(t *testing.T) {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "bob")         // nolint:errcheck
+	mw.WriteField("password", "hunter2") // nolint:errcheck
+
+	fw, _ := mw.CreateFormFile("upload", "photo.png")
+	fw.Write(bytes.Repeat([]byte{0x89}, 2048)) // nolint:errcheck
+	mw.Close()                                 // nolint:errcheck
+
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	expected := "name=bob\npassword=[redacted]\nupload=<file: photo.png, 2048 bytes>\n"
+	if got := jsonEvent(t, out.String(), "request")["body"]; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestLoggerMultipartBodyNoBoundary(t *testing.T) {
+	l := &coreLogger{}
+
+	h := http.Header{"Content-Type": []string{"multipart/form-data"}}
+	if got := l.multipartBody(h, []byte("payload")); got != "<multipart body: 7 bytes>" {
+		t.Errorf("expected a summary, got %q", got)
+	}
+}
+
+func TestLoggerMultipartBodyTruncated(t *testing.T) {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "bob") // nolint:errcheck
+
+	fw, _ := mw.CreateFormFile("upload", "big.bin")
+	fw.Write(bytes.Repeat([]byte{0}, 4096)) // nolint:errcheck
+	mw.Close()                              // nolint:errcheck
+
+	l := &coreLogger{}
+
+	h := http.Header{"Content-Type": []string{mw.FormDataContentType()}}
+
+	got := l.multipartBody(h, body.Bytes()[:body.Len()/2])
+	if !strings.HasPrefix(got, "name=bob\n") || strings.Contains(got, "\x00") {
+		t.Errorf("expected the parts before the cut with no file content, got %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerPathLevels(t *testing.T) {
+	l := Logger(NormalLevel, ioutil.Discard)
+	l.PathLevels = map[string]DetailLevel{
+		"/healthz":       NoneLevel,
+		"/api/":          MinimalLevel,
+		"/api/admin/":    DebugLevel,
+		"/api/admin/ops": VerboseLevel,
+	}
+
+	cases := []struct {
+		path     string
+		expected DetailLevel
+	}{
+		{path: "/", expected: NormalLevel},
+		{path: "/healthz", expected: NoneLevel},
+		{path: "/healthz/live", expected: NormalLevel},
+		{path: "/api", expected: NormalLevel},
+		{path: "/api/users", expected: MinimalLevel},
+		{path: "/api/admin/users", expected: DebugLevel},
+		{path: "/api/admin/ops", expected: VerboseLevel},
+		{path: "/api/admin/ops/x", expected: DebugLevel},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if got := l.requestLevel(r); got != c.expected {
+				t.Errorf("expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggerPathLevelsContextOverride(t *testing.T) {
+	l := Logger(NormalLevel, ioutil.Discard)
+	l.PathLevels = map[string]DetailLevel{"/api/": MinimalLevel}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r = r.WithContext(WithLogLevel(r.Context(), DebugLevel))
+
+	if got := l.requestLevel(r); got != DebugLevel {
+		t.Errorf("expected the context level to take precedence, got %v", got)
+	}
+}
+
+func TestLoggerPathLevelsHandler(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithFormat(JSONFormat))
+	l.PathLevels = map[string]DetailLevel{"/healthz": NoneLevel}
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if out.Len() != 0 {
+		t.Errorf("expected nothing logged, got %q", out.String())
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	if got := jsonEvent(t, out.String(), "response")["status"]; got != float64(http.StatusOK) {
+		t.Errorf("expected other paths logged, got %v", got)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	jsonHeader := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+	textHeader := http.Header{"Content-Type": {"text/plain"}}
+
+	cases := []struct {
+		name     string
+		header   http.Header
+		body     string
+		expected string
+	}{
+		{name: "compact", header: jsonHeader, body: `{"a":1,"b":[true]}`, expected: "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}"},
+		{name: "pretty", header: jsonHeader, body: "{\n  \"a\": 1\n}", expected: "{\n  \"a\": 1\n}"},
+		{name: "suffix", header: http.Header{"Content-Type": {"application/problem+json"}}, body: `{"a":1}`, expected: "{\n  \"a\": 1\n}"},
+		{name: "not JSON", header: textHeader, body: `{"a":1}`, expected: `{"a":1}`},
+		{name: "malformed", header: jsonHeader, body: `{"a":`, expected: `{"a":`},
+		{name: "empty", header: jsonHeader, body: "", expected: ""},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := prettyJSON(c.header, c.body); got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggerPrettyJSONBody(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(DebugLevel, &out).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`)) // nolint:errcheck
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !strings.Contains(out.String(), "{\n  \"ok\": true\n}") {
+		t.Errorf("expected an indented body in the log, got:\n%s", out.String())
+	}
+
+	if w.Body.String() != `{"ok":true}` {
+		t.Errorf("expected the client to receive the body unchanged, got %q", w.Body.String())
+	}
+}
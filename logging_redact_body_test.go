@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loggedJSONBody(t *testing.T, body string, opts ...Option) string {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, append([]Option{WithFormat(JSONFormat)}, opts...)...)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	logged, _ := jsonEvent(t, out.String(), "request")["body"].(string)
+
+	return logged
+}
+
+func TestLoggerRedactBodyFields(t *testing.T) {
+	cases := []struct {
+		name     string
+		fields   []string
+		body     string
+		expected string
+	}{
+		{
+			name:     "top level",
+			fields:   []string{"password"},
+			body:     `{"user":"bob","password":"hunter2"}`,
+			expected: `{"password":"[redacted]","user":"bob"}`,
+		},
+		{
+			name:     "case insensitive",
+			fields:   []string{"password"},
+			body:     `{"Password":"hunter2"}`,
+			expected: `{"Password":"[redacted]"}`,
+		},
+		{
+			name:     "nested",
+			fields:   []string{"user.ssn"},
+			body:     `{"ssn":"keep","user":{"name":"bob","ssn":"123-45-6789"}}`,
+			expected: `{"ssn":"keep","user":{"name":"bob","ssn":"[redacted]"}}`,
+		},
+		{
+			name:     "non-string value",
+			fields:   []string{"card"},
+			body:     `{"card":{"number":4111,"cvv":123}}`,
+			expected: `{"card":"[redacted]"}`,
+		},
+		{
+			name:     "array of objects",
+			fields:   []string{"users.token"},
+			body:     `{"users":[{"id":1,"token":"a"},{"id":2,"token":"b"}]}`,
+			expected: `{"users":[{"id":1,"token":"[redacted]"},{"id":2,"token":"[redacted]"}]}`,
+		},
+		{
+			name:     "top level array",
+			fields:   []string{"token"},
+			body:     `[{"token":"a"},{"token":"b"}]`,
+			expected: `[{"token":"[redacted]"},{"token":"[redacted]"}]`,
+		},
+		{
+			name:     "not set",
+			body:     `{"password":"hunter2"}`,
+			expected: `{"password":"hunter2"}`,
+		},
+		{
+			name:     "unparsed",
+			fields:   []string{"password"},
+			body:     `{"password":`,
+			expected: `<unparsed JSON body: 12 bytes>`,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := loggedJSONBody(t, c.body, WithRedactedBodyFields(c.fields...)); got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggerRedactBodyFieldsNotJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat), WithRedactedBodyFields("password"))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body) // nolint:errcheck
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"password":"hunter2"}`))
+	r.Header.Set("Content-Type", "text/plain")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := jsonEvent(t, out.String(), "request")["body"]; got != `{"password":"hunter2"}` {
+		t.Errorf("expected a text body logged as is, got %v", got)
+	}
+}
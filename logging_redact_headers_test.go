@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func loggedRequestHeaders(t *testing.T, opts ...Option) map[string]interface{} {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	l := Logger(VerboseLevel, &out, append([]Option{WithFormat(JSONFormat)}, opts...)...)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Api-Key", "key")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	headers, _ := jsonEvent(t, out.String(), "request")["headers"].(map[string]interface{})
+
+	return headers
+}
+
+func headerValue(headers map[string]interface{}, name string) interface{} {
+	if v, ok := headers[name].([]interface{}); ok && len(v) == 1 {
+		return v[0]
+	}
+
+	return headers[name]
+}
+
+func TestLoggerRedactHeadersDefault(t *testing.T) {
+	headers := loggedRequestHeaders(t)
+
+	if got := headerValue(headers, "Authorization"); got != "[redacted]" {
+		t.Errorf("expected Authorization redacted, got %v", got)
+	}
+
+	if got := headerValue(headers, "X-Api-Key"); got != "key" {
+		t.Errorf("expected X-Api-Key logged, got %v", got)
+	}
+}
+
+func TestLoggerRedactHeadersPerLogger(t *testing.T) {
+	custom := loggedRequestHeaders(t, WithRedactedHeaders("x-api-key"))
+	defaults := loggedRequestHeaders(t)
+
+	if got := headerValue(custom, "X-Api-Key"); got != "[redacted]" {
+		t.Errorf("expected X-Api-Key redacted, got %v", got)
+	}
+
+	if got := headerValue(custom, "Authorization"); got != "Bearer secret" {
+		t.Errorf("expected Authorization logged by the overriding logger, got %v", got)
+	}
+
+	if got := headerValue(defaults, "X-Api-Key"); got != "key" {
+		t.Errorf("expected another logger to keep the defaults, got %v", got)
+	}
+}
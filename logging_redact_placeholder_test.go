@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+func hashRedact(name, value string) string {
+	sum := sha256.Sum256([]byte(value))
+
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+func TestLoggerRedactPlaceholder(t *testing.T) {
+	headers := loggedRequestHeaders(t, WithRedactPlaceholder("***"))
+	if got := headerValue(headers, "Authorization"); got != "***" {
+		t.Errorf("expected %q, got %v", "***", got)
+	}
+
+	body := loggedJSONBody(t, `{"password":"hunter2"}`, WithRedactPlaceholder("***"), WithRedactedBodyFields("password"))
+	if body != `{"password":"***"}` {
+		t.Errorf("expected %q, got %q", `{"password":"***"}`, body)
+	}
+
+	l := &coreLogger{RedactPlaceholder: "***"}
+	if got := l.redactURL(&url.URL{RawQuery: "token=abc"}).RawQuery; got != "token=***" {
+		t.Errorf("expected %q, got %q", "token=***", got)
+	}
+}
+
+func TestLoggerRedactFunc(t *testing.T) {
+	opts := []Option{WithRedactPlaceholder("***"), WithRedactFunc(hashRedact)}
+
+	headers := loggedRequestHeaders(t, opts...)
+	if got, expected := headerValue(headers, "Authorization"), hashRedact("Authorization", "Bearer secret"); got != expected {
+		t.Errorf("expected %q, got %v", expected, got)
+	}
+
+	a := loggedJSONBody(t, `{"password":"hunter2"}`, append(opts, WithRedactedBodyFields("password"))...)
+	b := loggedJSONBody(t, `{"password":"hunter2"}`, append(opts, WithRedactedBodyFields("password"))...)
+	c := loggedJSONBody(t, `{"password":"letmein"}`, append(opts, WithRedactedBodyFields("password"))...)
+
+	if expected := `{"password":"` + hashRedact("password", "hunter2") + `"}`; a != expected {
+		t.Errorf("expected %q, got %q", expected, a)
+	}
+
+	if a != b || a == c {
+		t.Errorf("expected equal values to correlate and others not, got %q, %q and %q", a, b, c)
+	}
+}
+
+func TestLoggerRedactFuncName(t *testing.T) {
+	var names []string
+
+	l := &coreLogger{RedactFunc: func(name, value string) string {
+		names = append(names, name+"="+value)
+
+		return "x"
+	}}
+
+	l.redactURL(&url.URL{RawQuery: "access_token=abc"})
+
+	if len(names) != 1 || names[0] != "access_token=abc" {
+		t.Errorf("expected the parameter name and value, got %v", names)
+	}
+}
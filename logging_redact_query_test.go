@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactURL(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "none", query: "a=1&q=x%20y", expected: "a=1&q=x%20y"},
+		{name: "single", query: "a=1&access_token=abc&b=2", expected: "a=1&access_token=[redacted]&b=2"},
+		{name: "repeated", query: "token=abc&a=1&token=def", expected: "token=[redacted]&a=1&token=[redacted]"},
+		{name: "case insensitive", query: "API_KEY=abc", expected: "API_KEY=[redacted]"},
+		{name: "escaped name", query: "api%5Fkey=abc", expected: "api%5Fkey=[redacted]"},
+	}
+
+	l := &coreLogger{}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			u := &url.URL{Path: "/p", RawQuery: c.query}
+			if got := l.redactURL(u).RawQuery; got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+
+			if u.RawQuery != c.query {
+				t.Errorf("expected the original URL untouched, got %q", u.RawQuery)
+			}
+		})
+	}
+}
+
+func TestRedactURLCustomParams(t *testing.T) {
+	l := &coreLogger{RedactQueryParams: []string{"session"}}
+
+	u := &url.URL{Path: "/p", RawQuery: "session=abc&token=def"}
+	if got := l.redactURL(u).RawQuery; got != "session=[redacted]&token=def" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
+
+func TestLoggerRedactsDumpedRequestLine(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(VerboseLevel, &out).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query()["token"]; len(got) != 2 || got[0] != "abc" {
+			t.Errorf("expected the handler to see the real query, got %v", got)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/p?a=1&token=abc&token=def", nil))
+
+	if !strings.Contains(out.String(), "GET /p?a=1&token=[redacted]&token=[redacted] HTTP/1.1") {
+		t.Errorf("expected a redacted request line, got:\n%s", out.String())
+	}
+
+	if strings.Contains(out.String(), "abc") || strings.Contains(out.String(), "def") {
+		t.Errorf("expected no token values in the log, got:\n%s", out.String())
+	}
+}
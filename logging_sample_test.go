@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerSampleRate(t *testing.T) {
+	const (
+		n    = 4000
+		rate = 0.25
+	)
+
+	var out bytes.Buffer
+
+	served := 0
+	h := Logger(MinimalLevel, &out, WithFormat(JSONFormat), WithSampleRate(rate)).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { served++ }))
+
+	for i := 0; i < n; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if served != n {
+		t.Errorf("expected every request served, got %d", served)
+	}
+
+	var requests, responses int
+
+	for _, ev := range jsonEvents(t, out.String()) {
+		switch ev["kind"] {
+		case "request":
+			requests++
+		case "response":
+			responses++
+		}
+	}
+
+	if requests != responses {
+		t.Errorf("expected requests and responses sampled together, got %d and %d", requests, responses)
+	}
+
+	if got := float64(requests) / n; got < rate-0.05 || got > rate+0.05 {
+		t.Errorf("expected a sample rate near %v, got %v", rate, got)
+	}
+}
+
+func TestLoggerSampleRateBounds(t *testing.T) {
+	for _, rate := range []float64{0, 1} {
+		var out bytes.Buffer
+
+		h := Logger(MinimalLevel, &out, WithFormat(JSONFormat), WithSampleRate(rate)).Handler(http.NotFoundHandler())
+
+		for i := 0; i < 10; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		if got := len(jsonEvents(t, out.String())); got != 20 {
+			t.Errorf("expected every request logged at rate %v, got %d events", rate, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLoggerRedactsSetCookie(t *testing.T) {
+	var out bytes.Buffer
+
+	h := Logger(NormalLevel, &out, WithFormat(JSONFormat)).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	headers, _ := jsonEvent(t, out.String(), "response")["headers"].(map[string]interface{})
+
+	expected := []interface{}{"session=[redacted]; Path=/; HttpOnly", "theme=[redacted]"}
+	if got := headers["Set-Cookie"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if got := w.Header()["Set-Cookie"]; len(got) != 2 || got[0] != "session=s3cr3t; Path=/; HttpOnly" {
+		t.Errorf("expected the client to receive the cookies unchanged, got %v", got)
+	}
+}
+
+func TestRedactSetCookies(t *testing.T) {
+	redact := func(string, string) string { return "[redacted]" }
+
+	got := redactSetCookies([]string{"a=1; Secure", "b=2", "flag"}, redact)
+
+	expected := []string{"a=[redacted]; Secure", "b=[redacted]", "[redacted]"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoggerSetOutput(t *testing.T) {
+	var first, second bytes.Buffer
+
+	l := Logger(MinimalLevel, &first)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/before", nil))
+	l.SetOutput(&second)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/after", nil))
+	l.Log.Print("own message")
+
+	if got := strings.Count(first.String(), "(response)"); got != 1 {
+		t.Errorf("expected one entry before the switch, got %d in %q", got, first.String())
+	}
+
+	if got := strings.Count(second.String(), "(response)"); got != 1 {
+		t.Errorf("expected one entry after the switch, got %d in %q", got, second.String())
+	}
+
+	if strings.Contains(first.String(), "own message") || !strings.Contains(second.String(), "own message") {
+		t.Errorf("expected the logger's own messages redirected, got %q and %q", first.String(), second.String())
+	}
+}
+
+func TestLoggerSetOutputConcurrent(t *testing.T) {
+	var a, b syncBuffer
+
+	l := Logger(MinimalLevel, &a, WithFormat(JSONFormat))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	const n = 200
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+
+		if i%10 == 0 {
+			if i%20 == 0 {
+				l.SetOutput(&b)
+			} else {
+				l.SetOutput(&a)
+			}
+		}
+	}
+
+	wg.Wait()
+
+	// jsonEvents fails on any line torn across the switch.
+	var responses int
+
+	for _, e := range append(jsonEvents(t, a.String()), jsonEvents(t, b.String())...) {
+		if e["kind"] == "response" {
+			responses++
+		}
+	}
+
+	if responses != n {
+		t.Errorf("expected %d responses across both outputs, got %d", n, responses)
+	}
+}
+
+func TestRoundTripLoggerSetOutputKeepsCallerLog(t *testing.T) {
+	var first, second, own bytes.Buffer
+
+	shared := log.New(&own, "", 0)
+
+	rt := NewRoundTripLogger(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: r}, nil
+	}), MinimalLevel, &first, shared)
+
+	rt.SetOutput(&second)
+	shared.Print("caller message")
+
+	if _, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(own.String(), "caller message") {
+		t.Errorf("expected the caller's logger left on its own output, got %q", own.String())
+	}
+
+	if strings.Contains(second.String(), "caller message") || !strings.Contains(second.String(), "(response)") {
+		t.Errorf("expected only the entries redirected, got %q", second.String())
+	}
+}
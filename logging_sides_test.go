@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var logSidesCases = []struct {
+	name      string
+	requests  bool
+	responses bool
+}{
+	{name: "both", requests: true, responses: true},
+	{name: "requests only", requests: true},
+	{name: "responses only", responses: true},
+	{name: "neither"},
+}
+
+func loggedKinds(t *testing.T, out string) map[string]bool {
+	t.Helper()
+
+	kinds := map[string]bool{}
+	for _, e := range jsonEvents(t, out) {
+		kind, _ := e["kind"].(string)
+		kinds[kind] = true
+	}
+
+	return kinds
+}
+
+func TestLoggerLogRequestsResponses(t *testing.T) {
+	for _, c := range logSidesCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			l := Logger(VerboseLevel, &out, WithFormat(JSONFormat),
+				WithLogRequests(c.requests), WithLogResponses(c.responses))
+			h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello")) // nolint:errcheck
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Body.String() != "hello" {
+				t.Errorf("expected the response served, got %q", rec.Body.String())
+			}
+
+			kinds := loggedKinds(t, out.String())
+			if kinds["request"] != c.requests || kinds["response"] != c.responses {
+				t.Errorf("expected request %t and response %t, got %v", c.requests, c.responses, kinds)
+			}
+		})
+	}
+}
+
+func TestRoundTripLoggerLogRequestsResponses(t *testing.T) {
+	inner := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+			Request:    r,
+		}, nil
+	})
+
+	for _, c := range logSidesCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			rt := NewRoundTripLogger(inner, VerboseLevel, &out, log.New(ioutil.Discard, "", 0),
+				WithFormat(JSONFormat), WithLogRequests(c.requests), WithLogResponses(c.responses))
+
+			res, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close() // nolint:errcheck
+
+			if string(body) != "hello" {
+				t.Errorf("expected the response body intact, got %q", body)
+			}
+
+			kinds := loggedKinds(t, out.String())
+			if kinds["request"] != c.requests || kinds["response"] != c.responses {
+				t.Errorf("expected request %t and response %t, got %v", c.requests, c.responses, kinds)
+			}
+		})
+	}
+}
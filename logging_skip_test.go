@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerSkipPaths(t *testing.T) {
+	cases := []struct {
+		path   string
+		logged bool
+	}{
+		{path: "/healthz", logged: false},
+		{path: "/healthz/live", logged: true},
+		{path: "/healthzx", logged: true},
+		{path: "/debug/pprof/", logged: false},
+		{path: "/debug/pprof/heap", logged: false},
+		{path: "/debug/pprof", logged: true},
+		{path: "/api", logged: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.path, func(t *testing.T) {
+			var out bytes.Buffer
+
+			l := Logger(NormalLevel, &out)
+			l.SkipPaths = []string{"/healthz", "/debug/pprof/"}
+
+			served := false
+			h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			if !served {
+				t.Error("expected the request to be served")
+			}
+
+			if logged := out.Len() > 0; logged != c.logged {
+				t.Errorf("expected logged %t, got %q", c.logged, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerServerSentEvents(t *testing.T) {
+	var out syncBuffer
+
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(finished)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: event %d\n\n", i)
+
+			if i == 0 {
+				<-release
+			}
+		}
+	}))
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	line := make(chan string, 1)
+
+	br := bufio.NewReader(res.Body)
+
+	go func() {
+		s, _ := br.ReadString('\n')
+		line <- s
+	}()
+
+	select {
+	case got := <-line:
+		if got != "data: event 0\n" {
+			t.Errorf("expected the first event, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the first event before the handler returned")
+	}
+
+	close(release)
+	<-finished
+
+	rest, _ := br.ReadString(0)
+	if !strings.Contains(rest, "data: event 2\n") {
+		t.Errorf("expected the remaining events, got %q", rest)
+	}
+
+	srv.Close()
+
+	if got := jsonEvent(t, out.String(), "response")["body"]; got != nil && got != "" {
+		t.Errorf("expected the stream not captured, got %q", got)
+	}
+}
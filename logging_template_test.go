@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestLoggerSetTemplate(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out)
+
+	req := template.Must(template.New("req").Funcs(l.FuncMap(MinimalLevel)).Parse(
+		"> {{ .request.Method }} {{ url .request.URL }} from {{ .clientIP }}\n"))
+	if err := l.SetRequestTemplate(MinimalLevel, req); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := template.Must(template.New("resp").Funcs(l.FuncMap(MinimalLevel)).Parse(
+		"< {{ .response.StatusCode }} {{ status .response.StatusCode }}\n"))
+	if err := l.SetResponseTemplate(MinimalLevel, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/p?token=abc", nil))
+
+	expected := "> GET /p?token=[redacted] from 192.0.2.1\n< 202 Accepted\n"
+	if got := out.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestLoggerSetTemplateOtherLevels(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(NormalLevel, &out)
+	if err := l.SetRequestTemplate(MinimalLevel, template.Must(template.New("req").Parse("custom\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	h := l.Handler(http.NotFoundHandler())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := out.String(); strings.Contains(got, "custom") || !strings.Contains(got, "(request) example.com GET /") {
+		t.Errorf("expected the default template at other levels, got %q", got)
+	}
+}
+
+func TestLoggerSetTemplateNil(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out)
+	if err := l.SetRequestTemplate(MinimalLevel, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	h := l.Handler(http.NotFoundHandler())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := out.String(); got != " (response) 404 Not Found\n" {
+		t.Errorf("expected only the response entry, got %q", got)
+	}
+}
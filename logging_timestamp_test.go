@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerTimestampLayout(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   []Option
+		layout string
+	}{
+		{name: "default", layout: time.RFC3339},
+		{name: "custom", opts: []Option{WithTimestampLayout("2006-01-02 15:04:05.000")}, layout: "2006-01-02 15:04:05.000"},
+		{name: "nano", opts: []Option{WithTimestampLayout(time.RFC3339Nano)}, layout: time.RFC3339Nano},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			l := Logger(NormalLevel, &out, c.opts...)
+			h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			before := time.Now().Add(-time.Second)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			after := time.Now().Add(time.Second)
+
+			var stamped int
+
+			for _, line := range strings.Split(out.String(), "\n") {
+				i := strings.Index(line, "  (request)")
+				if i < 0 {
+					i = strings.Index(line, " (response)")
+				}
+
+				if i < 0 {
+					continue
+				}
+
+				ts, err := time.ParseInLocation(c.layout, line[:i], time.Local)
+				if err != nil {
+					t.Fatalf("expected a %q timestamp, got %q: %v", c.layout, line, err)
+				}
+
+				if ts.Before(before) || ts.After(after) {
+					t.Errorf("expected the time of logging, got %v", ts)
+				}
+
+				stamped++
+			}
+
+			if stamped != 2 {
+				t.Errorf("expected both entries timestamped, got %d in %q", stamped, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var errTransport = errors.New("connection refused")
+
+type errorRoundTripper struct{}
+
+func (errorRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errTransport
+}
+
+func TestRoundTripLoggerError(t *testing.T) {
+	var out bytes.Buffer
+
+	rt := NewRoundTripLogger(errorRoundTripper{}, MinimalLevel, &out, nil, WithFormat(JSONFormat))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/things", nil)
+	r = r.WithContext(WithRequestID(r.Context(), "req-1"))
+
+	if _, err := rt.RoundTrip(r); !errors.Is(err, errTransport) {
+		t.Fatalf("expected the transport error, got %v", err)
+	}
+
+	events := jsonEvents(t, out.String())
+	if len(events) != 2 || events[0]["kind"] != "request" {
+		t.Fatalf("expected the request then the error, got:\n%s", out.String())
+	}
+
+	ev := events[1]
+	if ev["kind"] != "error" || ev["request_id"] != "req-1" || ev["method"] != http.MethodGet ||
+		ev["host"] != "example.com" || ev["path"] != "/things" || ev["error"] != errTransport.Error() {
+		t.Errorf("unexpected error event: %v", ev)
+	}
+}
+
+func TestRoundTripLoggerErrorText(t *testing.T) {
+	var out bytes.Buffer
+
+	rt := NewRoundTripLogger(errorRoundTripper{}, DebugLevel, &out, nil)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/things", nil)
+	if _, err := rt.RoundTrip(r); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.Contains(out.String(), `(error) GET /things host=example.com error="connection refused"`) {
+		t.Errorf("expected the error logged, got:\n%s", out.String())
+	}
+}
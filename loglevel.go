@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const logLevelKeyName contextKey = "log-level-key"
+
+// LogLevelContextKey is the context key under which the detail level override
+// is stored. The RequestResponseLogger sets it, unset, for each request.
+const LogLevelContextKey = logLevelKeyName
+
+// levelOverride holds a per-request detail level, or -1 when none is set.
+type levelOverride struct {
+	level int32
+}
+
+// WithLogLevel overrides the detail level the RequestResponseLogger uses for
+// the request carrying ctx. Within a request served by the logger, the
+// override also takes effect when set by a downstream handler, for the
+// response entry.
+func WithLogLevel(ctx context.Context, level DetailLevel) context.Context {
+	if o, ok := ctx.Value(logLevelKeyName).(*levelOverride); ok {
+		atomic.StoreInt32(&o.level, int32(level))
+
+		return ctx
+	}
+
+	return context.WithValue(ctx, logLevelKeyName, &levelOverride{level: int32(level)})
+}
+
+// GetLogLevel returns the detail level override in the context and true if
+// one is set.
+func GetLogLevel(ctx context.Context) (DetailLevel, bool) {
+	o, ok := ctx.Value(logLevelKeyName).(*levelOverride)
+	if !ok {
+		return NoneLevel, false
+	}
+
+	level := atomic.LoadInt32(&o.level)
+	if level < 0 {
+		return NoneLevel, false
+	}
+
+	return DetailLevel(level), true
+}
+
+// withLevelOverride adds an unset override to the context for downstream
+// handlers to fill in, unless there is one already.
+func withLevelOverride(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(logLevelKeyName).(*levelOverride); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, logLevelKeyName, &levelOverride{level: -1})
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMaintenanceRetryAfter = time.Minute
+
+// NewMaintenance returns a handler that, while in maintenance, answers
+// requests with 503 Service Unavailable. When healthz is not nil, a
+// "maintenance" check that fails for the duration is registered on it, so
+// that readiness probes fail and load balancers drain the instance, without
+// touching the health mark set by SetHealthy or UnhealthyOnDone.
+func NewMaintenance(healthz *Healthz) *Maintenance {
+	h := &Maintenance{Allow: []string{"/healthz", "/healthz/"}}
+
+	if healthz != nil {
+		healthz.RegisterCheck("maintenance", h.check)
+	}
+
+	return h
+}
+
+// Maintenance is the handler responsible for maintenance mode.
+type Maintenance struct {
+	on int32 // accessed atomically
+
+	// Allow lists paths served even in maintenance. A path ending in a
+	// slash matches all paths below it. Defaults to the healthz endpoints.
+	Allow []string
+	// RetryAfter is sent in the Retry-After header. Defaults to 1m.
+	RetryAfter time.Duration
+	// Message is the body of the 503 response. Defaults to the status text.
+	Message string
+}
+
+var errInMaintenance = errors.New("in maintenance")
+
+// SetMaintenance turns maintenance mode on or off.
+func (h *Maintenance) SetMaintenance(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+
+	atomic.StoreInt32(&h.on, v)
+}
+
+// InMaintenance reports whether maintenance mode is on.
+func (h *Maintenance) InMaintenance() bool {
+	return atomic.LoadInt32(&h.on) == 1
+}
+
+// check is the health check registered with Healthz.
+func (h *Maintenance) check(context.Context) error {
+	if h.InMaintenance() {
+		return errInMaintenance
+	}
+
+	return nil
+}
+
+// ToggleHandler returns an http.Handler that reports the maintenance mode on
+// GET, and on POST sets it from the `on` form value, such as `on=true`, or
+// toggles it when there is none. Mount it behind authorization.
+func (h *Maintenance) ToggleHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			on := !h.InMaintenance()
+
+			if v := r.FormValue("on"); len(v) > 0 {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+					return
+				}
+
+				on = b
+			}
+
+			h.SetMaintenance(on)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("maintenance is: " + strconv.FormatBool(h.InMaintenance()))) // nolint:errcheck
+	})
+}
+
+// Handler implements the middleware interface.
+func (h *Maintenance) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.InMaintenance() || h.allowed(r.URL.Path) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		retry := h.RetryAfter
+		if retry <= 0 {
+			retry = defaultMaintenanceRetryAfter
+		}
+
+		msg := h.Message
+		if len(msg) == 0 {
+			msg = http.StatusText(http.StatusServiceUnavailable)
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		http.Error(w, msg, http.StatusServiceUnavailable)
+	})
+}
+
+func (h *Maintenance) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *Maintenance) allowed(path string) bool {
+	for _, p := range h.Allow {
+		if matchPath(path, p) {
+			return true
+		}
+	}
+
+	return false
+}
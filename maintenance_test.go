@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func maintenanceServer(m *Maintenance, hz *Healthz) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz/", http.StripPrefix("/healthz", hz))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("app")) }) // nolint:errcheck
+
+	return m.Handler(mux)
+}
+
+func maintenanceGet(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	return w
+}
+
+func TestMaintenanceOnOff(t *testing.T) {
+	hz := NewHealthz()
+	m := NewMaintenance(hz)
+	h := maintenanceServer(m, hz)
+
+	if w := maintenanceGet(h, "/x"); w.Code != http.StatusOK {
+		t.Errorf("off: expected 200, got %d", w.Code)
+	}
+
+	m.SetMaintenance(true)
+
+	w := maintenanceGet(h, "/x")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("on: expected 503, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("on: expected Retry-After 60, got %q", got)
+	}
+
+	m.SetMaintenance(false)
+
+	if w := maintenanceGet(h, "/x"); w.Code != http.StatusOK {
+		t.Errorf("off again: expected 200, got %d", w.Code)
+	}
+}
+
+func TestMaintenanceAllowlist(t *testing.T) {
+	hz := NewHealthz()
+	m := NewMaintenance(hz)
+	m.Allow = append(m.Allow, "/status")
+	h := maintenanceServer(m, hz)
+
+	m.SetMaintenance(true)
+
+	if w := maintenanceGet(h, "/status"); w.Code != http.StatusOK || w.Body.String() != "app" {
+		t.Errorf("/status: expected to bypass maintenance, got %d %q", w.Code, w.Body.String())
+	}
+
+	// The health endpoint is reached, and reports the maintenance.
+	w := maintenanceGet(h, "/healthz/")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "maintenance: in maintenance") {
+		t.Errorf("/healthz/: expected the failing maintenance check, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMaintenanceKeepsHealthMark(t *testing.T) {
+	hz := NewHealthz()
+	m := NewMaintenance(hz)
+	h := maintenanceServer(m, hz)
+
+	hz.SetHealthy(false)
+	m.SetMaintenance(true)
+	m.SetMaintenance(false)
+
+	if w := maintenanceGet(h, "/healthz/"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the unhealthy mark to survive maintenance, got %d", w.Code)
+	}
+
+	if hz.Healthy() {
+		t.Error("expected Healthy to stay false")
+	}
+}
+
+func TestMaintenanceToggleHandler(t *testing.T) {
+	m := NewMaintenance(nil)
+	toggle := m.ToggleHandler()
+
+	for _, tc := range []struct {
+		method, target string
+		code           int
+		on             bool
+	}{
+		{http.MethodGet, "/", http.StatusOK, false},
+		{http.MethodPost, "/", http.StatusOK, true},
+		{http.MethodPost, "/?on=true", http.StatusOK, true},
+		{http.MethodPost, "/?on=false", http.StatusOK, false},
+		{http.MethodPost, "/?on=maybe", http.StatusBadRequest, false},
+		{http.MethodDelete, "/", http.StatusMethodNotAllowed, false},
+	} {
+		w := httptest.NewRecorder()
+		toggle.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+
+		if w.Code != tc.code || m.InMaintenance() != tc.on {
+			t.Errorf("%s %s: expected %d with maintenance %v, got %d with %v",
+				tc.method, tc.target, tc.code, tc.on, w.Code, m.InMaintenance())
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// MaxBodySize returns a handler that limits request bodies to n bytes,
+// responding 413 Request Entity Too Large to requests over the limit.
+//
+// The logger captures request bodies only as they are read, so it may be
+// installed on either side of this handler; either way the logged body holds
+// no more than the n bytes let through.
+func MaxBodySize(n int64) *MaxBodySizeHandler {
+	return &MaxBodySizeHandler{Limit: n}
+}
+
+// MaxBodySizeHandler is the handler responsible for limiting the size of
+// request bodies. Bodies declared larger than Limit are rejected up front;
+// others are wrapped in http.MaxBytesReader, and once a read runs past the
+// limit an error response from the handler becomes 413 Request Entity Too
+// Large, as does no response at all.
+type MaxBodySizeHandler struct {
+	Limit int64
+}
+
+// Handler implements the middleware interface.
+func (h *MaxBodySizeHandler) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if r.ContentLength > h.Limit {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		if tee, ok := r.Body.(*teeBody); ok {
+			tee.clamp(h.Limit)
+		}
+
+		body := &limitedBody{limit: h.Limit}
+		body.ReadCloser = http.MaxBytesReader(w, r.Body, h.Limit)
+		r.Body = body
+
+		lw := &limitedWriter{ResponseWriter: w, body: body}
+		next.ServeHTTP(lw, r)
+
+		if body.exceeded() && !lw.wroteHeader {
+			lw.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	})
+}
+
+func (h *MaxBodySizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+// limitedBody wraps the http.MaxBytesReader around a request body, noting
+// when a read fails for running past the limit.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+
+	mu   sync.Mutex
+	read int64
+	over bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		b.over = true
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.over
+}
+
+// limitedWriter replaces an error response written after the body exceeded
+// its limit with 413 Request Entity Too Large, discarding the handler's own
+// error body.
+type limitedWriter struct {
+	http.ResponseWriter
+	body *limitedBody
+
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *limitedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if code >= http.StatusBadRequest && w.body.exceeded() {
+		w.replaced = true
+		w.Header().Del("Content-Length")
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.replaced {
+		return len(p), nil
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher when the underlying writer does, committing
+// the status like net/http does.
+func (w *limitedWriter) Flush() {
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	f.Flush()
+}
+
+// Hijack implements http.Hijacker when the underlying writer does. Nothing is
+// written once the connection is hijacked.
+func (w *limitedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+
+	return conn, rw, err
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readingHandler reads the whole body, answering 400 Bad Request on a read
+// error like a typical handler would.
+func readingHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+
+		return
+	}
+
+	w.Write(b) // nolint:errcheck
+}
+
+func TestMaxBodySizeUnder(t *testing.T) {
+	h := MaxBodySize(10).Handler(http.HandlerFunc(readingHandler))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+
+	if w.Code != http.StatusOK || w.Body.String() != "small" {
+		t.Errorf("expected the body echoed, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMaxBodySizeOverDeclared(t *testing.T) {
+	called := false
+	h := MaxBodySize(10).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 11))))
+
+	if w.Code != http.StatusRequestEntityTooLarge || called {
+		t.Errorf("expected 413 before the handler runs, got %d, called %t", w.Code, called)
+	}
+}
+
+func TestMaxBodySizeOverStreamed(t *testing.T) {
+	h := MaxBodySize(10).Handler(http.HandlerFunc(readingHandler))
+
+	r := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 100))))
+	r.ContentLength = -1
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+
+	if strings.Contains(w.Body.String(), "bad body") {
+		t.Errorf("expected the handler's error body replaced, got %q", w.Body.String())
+	}
+}
+
+func TestMaxBodySizeLoggedBody(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(DebugLevel, &out, WithFormat(JSONFormat))
+	h := l.Handler(MaxBodySize(4).Handler(http.HandlerFunc(readingHandler)))
+
+	r := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(strings.NewReader("abcdefgh")))
+	r.Header.Set("Content-Type", "text/plain")
+	r.ContentLength = -1
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if body := jsonEvent(t, out.String(), "request")["body"]; body != "abcd… (truncated)" {
+		t.Errorf("expected at most the limit logged, got %q", body)
+	}
+}
+
+func TestMaxBodySizeFlush(t *testing.T) {
+	release := make(chan struct{})
+
+	h := MaxBodySize(10).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)       // nolint:errcheck
+		w.Write([]byte("partial\n")) // nolint:errcheck
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL, "text/plain", strings.NewReader("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	close(release)
+
+	if err != nil || line != "partial\n" {
+		t.Errorf("expected the flushed write before the handler returned, got %q, %v", line, err)
+	}
+}
+
+func TestMaxBodySizeHijack(t *testing.T) {
+	h := MaxBodySize(10).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != "hello" {
+			t.Errorf("expected the body before the upgrade, got %q", b)
+		}
+
+		echoUpgrade(t)(w, r)
+	}))
+
+	upgradeEcho(t, h, "hello")
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMethodOverrideHeader = "X-HTTP-Method-Override"
+	methodOverrideField         = "_method"
+)
+
+// NewMethodOverride returns a handler that lets POST requests from HTML
+// forms and clients limited to GET and POST stand in for PUT, PATCH and
+// DELETE.
+func NewMethodOverride() *MethodOverride {
+	return &MethodOverride{Header: defaultMethodOverrideHeader}
+}
+
+// MethodOverride is the handler responsible for method overrides. The method
+// of a POST request is replaced by the value of Header or, for URL-encoded
+// form posts, of the `_method` form field. Multipart bodies, which may carry
+// large uploads, are left unread. Values other than PUT, PATCH and DELETE are
+// ignored.
+type MethodOverride struct {
+	// Header names the override header. Defaults to X-HTTP-Method-Override.
+	Header string
+}
+
+// Handler implements the middleware interface.
+func (h *MethodOverride) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if method, ok := h.override(r); ok {
+				r.Method = method
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *MethodOverride) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+// override returns the validated override method of r, if any.
+func (h *MethodOverride) override(r *http.Request) (string, bool) {
+	header := h.Header
+	if len(header) == 0 {
+		header = defaultMethodOverrideHeader
+	}
+
+	method := r.Header.Get(header)
+	if len(method) == 0 && HasContentType(r.Header, "application/x-www-form-urlencoded") {
+		method = r.PostFormValue(methodOverrideField)
+	}
+
+	switch method = strings.ToUpper(strings.TrimSpace(method)); method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return method, true
+	}
+
+	return "", false
+}
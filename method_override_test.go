@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodOverride(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		header   string
+		form     string
+		expected string
+	}{
+		{name: "none", method: http.MethodPost, expected: http.MethodPost},
+		{name: "header", method: http.MethodPost, header: "PUT", expected: http.MethodPut},
+		{name: "header lower case", method: http.MethodPost, header: " delete ", expected: http.MethodDelete},
+		{name: "form", method: http.MethodPost, form: "_method=PATCH", expected: http.MethodPatch},
+		{name: "header over form", method: http.MethodPost, header: "PUT", form: "_method=DELETE", expected: http.MethodPut},
+		{name: "invalid header", method: http.MethodPost, header: "CONNECT", expected: http.MethodPost},
+		{name: "invalid form", method: http.MethodPost, form: "_method=GET", expected: http.MethodPost},
+		{name: "not post", method: http.MethodGet, header: "DELETE", expected: http.MethodGet},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var got string
+
+			h := NewMethodOverride().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Method
+			}))
+
+			r := httptest.NewRequest(c.method, "/", strings.NewReader(c.form))
+			if len(c.header) > 0 {
+				r.Header.Set("X-HTTP-Method-Override", c.header)
+			}
+
+			if len(c.form) > 0 {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestMethodOverrideCustomHeader(t *testing.T) {
+	var got string
+
+	mo := &MethodOverride{Header: "X-Method"}
+	h := mo.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Method", "DELETE")
+	r.Header.Set("X-HTTP-Method-Override", "PUT")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != http.MethodDelete {
+		t.Errorf("expected %s, got %s", http.MethodDelete, got)
+	}
+}
+
+func TestMethodOverrideIgnoresJSONBody(t *testing.T) {
+	var body string
+
+	h := NewMethodOverride().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"_method":"DELETE"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if body != `{"_method":"DELETE"}` {
+		t.Errorf("expected the body left unread, got %q", body)
+	}
+}
+
+func TestMethodOverrideIgnoresMultipartBody(t *testing.T) {
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("_method", "DELETE") // nolint:errcheck
+	mw.Close()                         // nolint:errcheck
+
+	var (
+		method string
+		field  string
+	)
+
+	h := NewMethodOverride().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.MultipartForm != nil {
+			t.Error("expected the multipart form left unparsed")
+		}
+
+		method = r.Method
+		field = r.FormValue("_method")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if method != http.MethodPost {
+		t.Errorf("expected %s, got %s", http.MethodPost, method)
+	}
+
+	if field != "DELETE" {
+		t.Errorf("expected the body left for the handler, got %q", field)
+	}
+}
@@ -0,0 +1,324 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nolint:gochecknoglobals
+var (
+	// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+	// histogram buckets used when Metrics.Buckets is not set.
+	DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// DefaultMetricsRegistry is the registry used by Metrics created without
+	// one of their own, and served by MetricsHandler.
+	DefaultMetricsRegistry = NewMetricsRegistry()
+
+	metricsMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+	}
+)
+
+// MetricsHandler returns an http.Handler serving the metrics in
+// DefaultMetricsRegistry, usually mounted at `/metrics`.
+func MetricsHandler() http.Handler {
+	return DefaultMetricsRegistry
+}
+
+// NewMetricsRegistry returns an empty registry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{}
+}
+
+// MetricsRegistry collects the metrics of the Metrics handlers registered with
+// it and serves them in the Prometheus text exposition format.
+type MetricsRegistry struct {
+	mu      sync.Mutex
+	metrics []*Metrics
+}
+
+func (reg *MetricsRegistry) register(m *Metrics) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.metrics = append(reg.metrics, m)
+}
+
+func (reg *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	metrics := reg.metrics
+	reg.mu.Unlock()
+
+	// Metrics sharing a namespace are merged, so each metric family is
+	// written once.
+	var namespaces []*Metrics
+
+	merged := map[string]*Metrics{}
+
+	for _, m := range metrics {
+		ns := m.namespace()
+
+		t, ok := merged[ns]
+		if !ok {
+			t = &Metrics{
+				Namespace: ns,
+				requests:  map[requestKey]uint64{},
+				latencies: map[string]*histogram{},
+				sizes:     map[string]uint64{},
+			}
+			merged[ns] = t
+			namespaces = append(namespaces, t)
+		}
+
+		t.merge(m)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	for _, m := range namespaces {
+		m.writeTo(w)
+	}
+}
+
+// NewMetrics returns a handler recording request metrics in registry, or in
+// DefaultMetricsRegistry when registry is nil. The series of Metrics sharing
+// a registry and namespace are summed, and their latency histograms must use
+// the same Buckets; a histogram with other buckets is left out.
+func NewMetrics(registry *MetricsRegistry) *Metrics {
+	if registry == nil {
+		registry = DefaultMetricsRegistry
+	}
+
+	m := &Metrics{
+		requests:  map[requestKey]uint64{},
+		latencies: map[string]*histogram{},
+		sizes:     map[string]uint64{},
+	}
+	registry.register(m)
+
+	return m
+}
+
+// Metrics is the handler responsible for recording the rate, errors and
+// duration of requests: a counter of requests by method and status class
+// ("2xx", "4xx", ...), a histogram of their latency by method, and a counter of
+// response body bytes by method. Methods
+// other than the standard ones are counted as "other".
+type Metrics struct {
+	// Namespace prefixes the metric names. Defaults to "http", giving
+	// http_requests_total and http_request_duration_seconds.
+	Namespace string
+	// Buckets are the upper bounds of the latency histogram buckets, in
+	// seconds. Defaults to DefaultLatencyBuckets. Set before serving.
+	Buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[string]*histogram
+	sizes     map[string]uint64
+}
+
+type requestKey struct {
+	method string
+	class  string
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+// Handler implements the middleware interface.
+func (m *Metrics) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.observe(r.Method, status, time.Since(start), rw.written())
+	})
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	m.Handler(next).ServeHTTP(w, r)
+}
+
+func (m *Metrics) observe(method string, status int, d time.Duration, bytes int64) {
+	if !matchAny(method, metricsMethods...) {
+		method = "other"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method: method, class: fmt.Sprintf("%dxx", status/100)}]++
+	m.sizes[method] += uint64(bytes)
+
+	h, ok := m.latencies[method]
+	if !ok {
+		bounds := m.Buckets
+		if len(bounds) == 0 {
+			bounds = DefaultLatencyBuckets
+		}
+
+		bounds = append([]float64(nil), bounds...)
+		sort.Float64s(bounds)
+
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		m.latencies[method] = h
+	}
+
+	h.observe(d.Seconds())
+}
+
+func (m *Metrics) namespace() string {
+	if len(m.Namespace) == 0 {
+		return "http"
+	}
+
+	return m.Namespace
+}
+
+// merge adds the series of o to those of m.
+func (m *Metrics) merge(o *Metrics) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for k, n := range o.requests {
+		m.requests[k] += n
+	}
+
+	for method, n := range o.sizes {
+		m.sizes[method] += n
+	}
+
+	for method, h := range o.latencies {
+		t, ok := m.latencies[method]
+		if !ok {
+			m.latencies[method] = &histogram{
+				bounds: h.bounds,
+				counts: append([]uint64(nil), h.counts...),
+				sum:    h.sum,
+				count:  h.count,
+			}
+
+			continue
+		}
+
+		if !equalBounds(t.bounds, h.bounds) {
+			continue
+		}
+
+		for i, n := range h.counts {
+			t.counts[i] += n
+		}
+
+		t.sum += h.sum
+		t.count += h.count
+	}
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) writeTo(w io.Writer) {
+	ns := m.namespace()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+
+		return keys[i].class < keys[j].class
+	})
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# HELP %s_requests_total Total number of HTTP requests.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_requests_total counter\n", ns)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s_requests_total{method=%q,code=%q} %d\n", ns, k.method, k.class, m.requests[k])
+	}
+
+	methods := make([]string, 0, len(m.latencies))
+	for method := range m.latencies {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	fmt.Fprintf(&b, "# HELP %s_request_duration_seconds Duration of HTTP requests.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_request_duration_seconds histogram\n", ns)
+
+	for _, method := range methods {
+		h := m.latencies[method]
+
+		for i, bound := range h.bounds {
+			fmt.Fprintf(&b, "%s_request_duration_seconds_bucket{method=%q,le=%q} %d\n",
+				ns, method, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+
+		fmt.Fprintf(&b, "%s_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", ns, method, h.count)
+		fmt.Fprintf(&b, "%s_request_duration_seconds_sum{method=%q} %s\n",
+			ns, method, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_request_duration_seconds_count{method=%q} %d\n", ns, method, h.count)
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_response_size_bytes_total Total bytes of HTTP response bodies.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_response_size_bytes_total counter\n", ns)
+
+	for _, method := range methods {
+		fmt.Fprintf(&b, "%s_response_size_bytes_total{method=%q} %d\n", ns, method, m.sizes[method])
+	}
+
+	io.WriteString(w, b.String()) // nolint:errcheck
+}
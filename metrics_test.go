@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var expositionLine = regexp.MustCompile(
+	`^(# (HELP|TYPE) [a-z_]+ .+|[a-z_]+\{([a-z]+="[^"]*",?)+\} [0-9.e+-]+)$`)
+
+func metricsText(reg *MetricsRegistry) string {
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	return w.Body.String()
+}
+
+func TestMetricsCounters(t *testing.T) {
+	reg := NewMetricsRegistry()
+	m := NewMetrics(reg)
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+		httptest.NewRequest(http.MethodPost, "/", nil),
+		httptest.NewRequest("BREW", "/", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	text := metricsText(reg)
+
+	for _, line := range []string{
+		`http_requests_total{method="GET",code="2xx"} 2`,
+		`http_requests_total{method="GET",code="4xx"} 1`,
+		`http_requests_total{method="POST",code="2xx"} 1`,
+		`http_requests_total{method="other",code="2xx"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",le="+Inf"} 3`,
+		`http_request_duration_seconds_count{method="GET"} 3`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	reg := NewMetricsRegistry()
+
+	m := NewMetrics(reg)
+	m.Namespace = "api"
+	m.Buckets = []float64{1, 0.1}
+
+	m.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	text := w.Body.String()
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if !expositionLine.MatchString(line) {
+			t.Errorf("invalid exposition line %q", line)
+		}
+
+		if !strings.HasPrefix(line, "api_") && !strings.HasPrefix(line, "# HELP api_") &&
+			!strings.HasPrefix(line, "# TYPE api_") {
+			t.Errorf("expected the api namespace, got %q", line)
+		}
+	}
+
+	bucket01 := strings.Index(text, `le="0.1"`)
+	bucket1 := strings.Index(text, `le="1"`)
+
+	if bucket01 < 0 || bucket1 < bucket01 {
+		t.Errorf("expected sorted buckets, got:\n%s", text)
+	}
+}
+
+func TestMetricsSeparateRegistries(t *testing.T) {
+	a, b := NewMetricsRegistry(), NewMetricsRegistry()
+
+	NewMetrics(a).Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !strings.Contains(metricsText(a), "http_requests_total{") {
+		t.Error("expected the request recorded in its registry")
+	}
+
+	if len(metricsText(b)) > 0 {
+		t.Errorf("expected an empty registry, got:\n%s", metricsText(b))
+	}
+}
+
+func TestMetricsSharedNamespace(t *testing.T) {
+	reg := NewMetricsRegistry()
+	a, b, other := NewMetrics(reg), NewMetrics(reg), NewMetrics(reg)
+	other.Buckets = []float64{42}
+
+	for _, m := range []*Metrics{a, b, other} {
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok")) // nolint:errcheck
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	text := metricsText(reg)
+
+	for _, family := range []string{"http_requests_total", "http_request_duration_seconds", "http_response_size_bytes_total"} {
+		if n := strings.Count(text, "# TYPE "+family+" "); n != 1 {
+			t.Errorf("expected one TYPE line for %s, got %d in:\n%s", family, n, text)
+		}
+	}
+
+	for _, line := range []string{
+		`http_requests_total{method="GET",code="2xx"} 3`,
+		`http_response_size_bytes_total{method="GET"} 6`,
+		`http_request_duration_seconds_count{method="GET"} 2`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, text)
+		}
+	}
+
+	if strings.Contains(text, `le="42"`) {
+		t.Errorf("expected the histogram with other buckets left out, got:\n%s", text)
+	}
+}
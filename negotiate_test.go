@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offered := []string{"application/json", "text/html", "text/plain"}
+
+	cases := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{name: "no accept", expected: "application/xml"},
+		{name: "exact", accept: "text/html", expected: "text/html"},
+		{name: "weighted", accept: "application/json;q=0.5, text/plain;q=0.9", expected: "text/plain"},
+		{name: "default weight", accept: "text/html;q=0.8, application/json", expected: "application/json"},
+		{name: "subtype wildcard", accept: "application/xml, text/*;q=0.5", expected: "text/html"},
+		{name: "full wildcard", accept: "*/*", expected: "application/json"},
+		{name: "more specific wins", accept: "text/*;q=0.9, text/plain;q=0.9", expected: "text/plain"},
+		{name: "specific exclusion", accept: "text/*, text/html;q=0", expected: "text/plain"},
+		{name: "no match", accept: "image/png", expected: "application/xml"},
+		{name: "all excluded", accept: "*/*;q=0", expected: "application/xml"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			h := http.Header{}
+			if len(c.accept) > 0 {
+				h.Set("Accept", c.accept)
+			}
+
+			if got := NegotiateContentType(h, offered, "application/xml"); got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
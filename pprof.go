@@ -2,23 +2,195 @@ package middleware
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/http/pprof"
+	"path"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"strings"
 )
 
+// PprofOption configures the handlers returned by PprofHandler and its
+// variants.
+type PprofOption func(*pprofConfig)
+
+// nolint:gochecknoglobals
+var (
+	// pprofIndexed are the routes listed on the index page, which leaves out the
+	// symbol lookup used by tools.
+	pprofIndexed = []string{
+		"allocs", "block", "cmdline", "goroutine", "heap", "mutex", "profile", "threadcreate", "trace",
+	}
+
+	// pprofIndexTemplate renders the index page like pprof.Index does, linking
+	// to the profiles relative to it.
+	pprofIndexTemplate = template.Must(template.New("index").Parse(`<html>
+<head>
+<title>{{ .Prefix }}/</title>
+</head>
+<body>
+{{ .Prefix }}/<br>
+<br>
+Types of profiles available:
+<table>
+<thead><td>Count</td><td>Profile</td></thead>
+{{ range .Profiles }}<tr><td>{{ .Count }}</td><td><a href="{{ .Href }}">{{ .Name }}</a></td></tr>
+{{ end }}</table>
+{{ if .Goroutine }}<a href="goroutine?debug=2">full goroutine stack dump</a>
+{{ end }}</body>
+</html>
+`))
+)
+
+type pprofConfig struct {
+	enabled  []string
+	disabled []string
+}
+
+// WithPprofProfiles mounts only the named routes, such as "heap" or
+// "profile"; the rest respond 404 Not Found.
+func WithPprofProfiles(names ...string) PprofOption {
+	return func(c *pprofConfig) {
+		c.enabled = append(c.enabled, names...)
+	}
+}
+
+// WithoutPprofProfiles leaves the named routes, such as "cmdline" or "trace",
+// unmounted so that they respond 404 Not Found.
+func WithoutPprofProfiles(names ...string) PprofOption {
+	return func(c *pprofConfig) {
+		c.disabled = append(c.disabled, names...)
+	}
+}
+
+func (c pprofConfig) mounted(name string) bool {
+	if len(c.enabled) > 0 && !matchAny(name, c.enabled...) {
+		return false
+	}
+
+	return !matchAny(name, c.disabled...)
+}
+
 // PprofHandler returns an http.Handler for default pprof endpoints at `/debug/pprof/`.
-func PprofHandler() http.Handler {
+func PprofHandler(opts ...PprofOption) http.Handler {
+	return PprofHandlerWithPrefix("/pprof", opts...)
+}
+
+// PprofHandlerWithPrefix returns an http.Handler serving the pprof endpoints
+// under prefix, with the index at prefix/. The bare prefix redirects there,
+// since the index links to the profiles relative to it.
+func PprofHandlerWithPrefix(prefix string, opts ...PprofOption) http.Handler {
+	var c pprofConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	routes := map[string]http.Handler{
+		"cmdline": http.HandlerFunc(pprof.Cmdline),
+		"profile": http.HandlerFunc(pprof.Profile),
+		"symbol":  http.HandlerFunc(pprof.Symbol),
+		"trace":   http.HandlerFunc(pprof.Trace),
+	}
+	for _, name := range pprofIndexed {
+		if _, ok := routes[name]; !ok {
+			routes[name] = pprof.Handler(name)
+		}
+	}
+
 	m := http.NewServeMux()
 
-	m.HandleFunc("/pprof", pprof.Index)
-	m.HandleFunc("/pprof/cmdline", pprof.Cmdline)
-	m.HandleFunc("/pprof/profile", pprof.Profile)
-	m.HandleFunc("/pprof/symbol", pprof.Symbol)
-	m.HandleFunc("/pprof/trace", pprof.Trace)
+	// The bare prefix is redirected here rather than by ServeMux, whose
+	// absolute redirect would drop any prefix stripped by a parent.
+	if len(prefix) > 0 {
+		m.Handle(prefix, pprofRedirect(prefix))
+	}
+
+	m.Handle(prefix+"/", pprofIndex(prefix, c))
 
-	for _, extra := range []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"} {
-		m.Handle(fmt.Sprintf("/pprof/%s", extra), pprof.Handler(extra))
+	for name, h := range routes {
+		if c.mounted(name) {
+			m.Handle(fmt.Sprintf("%s/%s", prefix, name), h)
+		}
 	}
 
 	return m
 }
+
+// pprofRedirect redirects the bare prefix to prefix/, with a Location
+// relative to the request so that it holds behind a stripped parent prefix.
+func pprofRedirect(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loc := path.Base(prefix) + "/"
+		if len(r.URL.RawQuery) > 0 {
+			loc += "?" + r.URL.RawQuery
+		}
+
+		w.Header().Set("Location", loc)
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+}
+
+type pprofProfile struct {
+	Name  string
+	Href  string
+	Count string
+}
+
+// pprofIndex serves an index page at prefix/ listing the mounted routes.
+func pprofIndex(prefix string, c pprofConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prefix+"/" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		var profiles []pprofProfile
+
+		for _, name := range pprofIndexed {
+			if !c.mounted(name) {
+				continue
+			}
+
+			p := pprofProfile{Name: name, Href: name}
+			if prof := runtimepprof.Lookup(name); prof != nil {
+				p.Href += "?debug=1"
+				p.Count = strconv.Itoa(prof.Count())
+			}
+
+			profiles = append(profiles, p)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		// nolint:errcheck
+		pprofIndexTemplate.Execute(w, struct {
+			Prefix    string
+			Profiles  []pprofProfile
+			Goroutine bool
+		}{prefix, profiles, c.mounted("goroutine")})
+	})
+}
+
+// PprofHandlerWithAuth returns the PprofHandler endpoints guarded by
+// authorize, responding 403 Forbidden to requests it rejects.
+func PprofHandlerWithAuth(authorize func(*http.Request) bool, opts ...PprofOption) http.Handler {
+	next := PprofHandler(opts...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
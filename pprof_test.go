@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func pprofGet(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	return w
+}
+
+func TestPprofHandlerIndex(t *testing.T) {
+	h := http.StripPrefix("/debug", PprofHandler())
+
+	w := pprofGet(h, "/debug/pprof/")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected the index page, got %q", w.Body.String())
+	}
+}
+
+func TestPprofHandlerIndexRedirect(t *testing.T) {
+	h := http.StripPrefix("/debug", PprofHandler())
+
+	w := pprofGet(h, "/debug/pprof?x=1")
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected 301, got %d", w.Code)
+	}
+
+	base, _ := url.Parse("http://example.com/debug/pprof?x=1")
+	if loc, _ := base.Parse(w.Header().Get("Location")); loc.RequestURI() != "/debug/pprof/?x=1" {
+		t.Errorf("expected a redirect to the index keeping the stripped prefix, got %q", w.Header().Get("Location"))
+	}
+}
+
+func TestPprofHandlerProfiles(t *testing.T) {
+	h := PprofHandler()
+
+	if w := pprofGet(h, "/pprof/goroutine?debug=1"); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+
+	if w := pprofGet(h, "/pprof/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestPprofHandlerWithPrefix(t *testing.T) {
+	h := PprofHandlerWithPrefix("/internal/profiling/")
+
+	for _, path := range []string{"/internal/profiling/", "/internal/profiling/heap"} {
+		if w := pprofGet(h, path); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, w.Code)
+		}
+	}
+
+	if w := pprofGet(h, "/internal/profiling"); w.Header().Get("Location") != "profiling/" {
+		t.Errorf("expected a redirect to the index, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := pprofGet(h, "/pprof/heap"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 at the default prefix, got %d", w.Code)
+	}
+}
+
+func TestPprofHandlerWithAuth(t *testing.T) {
+	h := PprofHandlerWithAuth(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer ops"
+	})
+
+	for _, path := range []string{"/pprof/", "/pprof/heap", "/pprof/cmdline"} {
+		if w := pprofGet(h, path); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 without credentials, got %d", path, w.Code)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer ops")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 with credentials, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestPprofProfilesAllowlist(t *testing.T) {
+	h := PprofHandler(WithPprofProfiles("heap", "profile"))
+
+	if w := pprofGet(h, "/pprof/heap"); w.Code != http.StatusOK {
+		t.Errorf("expected heap to be mounted, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/pprof/cmdline", "/pprof/trace", "/pprof/goroutine"} {
+		if w := pprofGet(h, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestPprofProfilesDenylist(t *testing.T) {
+	h := PprofHandler(WithoutPprofProfiles("cmdline", "trace"))
+
+	for _, path := range []string{"/pprof/cmdline", "/pprof/trace"} {
+		if w := pprofGet(h, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+
+	if w := pprofGet(h, "/pprof/heap"); w.Code != http.StatusOK {
+		t.Errorf("expected heap to stay mounted, got %d", w.Code)
+	}
+}
+
+func TestPprofIndexListsMounted(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    []PprofOption
+		listed  []string
+		omitted []string
+	}{
+		{
+			name:    "all",
+			listed:  []string{`href="heap?debug=1"`, `href="cmdline"`, `href="trace"`, `href="goroutine?debug=2"`},
+			omitted: []string{`href="symbol"`},
+		},
+		{
+			name:    "allowlist",
+			opts:    []PprofOption{WithPprofProfiles("heap", "profile")},
+			listed:  []string{`href="heap?debug=1"`, `href="profile"`},
+			omitted: []string{`href="cmdline"`, `href="trace"`, `href="goroutine?debug=1"`, `href="goroutine?debug=2"`},
+		},
+		{
+			name:    "denylist",
+			opts:    []PprofOption{WithoutPprofProfiles("cmdline", "trace")},
+			listed:  []string{`href="heap?debug=1"`, `href="goroutine?debug=1"`},
+			omitted: []string{`href="cmdline"`, `href="trace"`},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			w := pprofGet(PprofHandler(c.opts...), "/pprof/")
+			body := w.Body.String()
+
+			for _, s := range c.listed {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected %s in the index:\n%s", s, body)
+				}
+			}
+
+			for _, s := range c.omitted {
+				if strings.Contains(body, s) {
+					t.Errorf("expected no %s in the index:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// NewPriorityShedder returns a handler that, while more than threshold
+// requests are in flight, rejects requests with a priority below minPriority
+// with 503 Service Unavailable. A nil priority func gives every request
+// priority zero.
+func NewPriorityShedder(threshold int64, minPriority int, priority func(*http.Request) int) *PriorityShedder {
+	return &PriorityShedder{Threshold: threshold, MinPriority: minPriority, priority: priority}
+}
+
+// PriorityShedder is the handler responsible for shedding low priority
+// traffic under load.
+type PriorityShedder struct {
+	inflight int64 // accessed atomically; kept first for 64-bit alignment
+
+	Threshold int64
+	// MinPriority is the lowest priority admitted while overloaded.
+	MinPriority int
+	Log         *log.Logger
+
+	priority func(*http.Request) int
+}
+
+// HeaderPriority returns a priority func reading an integer priority from the
+// named header, falling back to def.
+func HeaderPriority(header string, def int) func(*http.Request) int {
+	return func(r *http.Request) int {
+		if p, err := strconv.Atoi(r.Header.Get(header)); err == nil {
+			return p
+		}
+
+		return def
+	}
+}
+
+// PathPriority returns a priority func using the priority of the longest
+// matching path prefix, falling back to def.
+func PathPriority(priorities map[string]int, def int) func(*http.Request) int {
+	return func(r *http.Request) int {
+		p, longest := def, -1
+
+		for prefix, priority := range priorities {
+			if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > longest {
+				p, longest = priority, len(prefix)
+			}
+		}
+
+		return p
+	}
+}
+
+// Handler implements the middleware interface.
+func (h *PriorityShedder) Handler(next http.Handler) http.Handler {
+	logger := h.Log
+	if logger == nil {
+		logger = log.New(os.Stderr, " [priority shedder] ", log.LstdFlags)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&h.inflight, 1)
+		defer atomic.AddInt64(&h.inflight, -1)
+
+		if n > h.Threshold {
+			if p := h.priorityOf(r); p < h.MinPriority {
+				id, _ := GetRequestID(r.Context())
+				logger.Printf("shed request_id=%q method=%s path=%q priority=%d inflight=%d", id, r.Method, r.URL.Path, p, n)
+
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *PriorityShedder) priorityOf(r *http.Request) int {
+	if h.priority == nil {
+		return 0
+	}
+
+	return h.priority(r)
+}
+
+func (h *PriorityShedder) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
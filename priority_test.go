@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// shedderUnderLoad serves r through h while another request is held in
+// flight, returning the response.
+func shedderUnderLoad(h *PriorityShedder, r *http.Request) *httptest.ResponseRecorder {
+	h.Log = log.New(ioutil.Discard, "", 0)
+
+	entered, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			close(entered)
+			<-release
+		}
+	}))
+
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hold", nil))
+	}()
+
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	close(release)
+	<-done
+
+	return rec
+}
+
+func TestPriorityShedder(t *testing.T) {
+	cases := []struct {
+		name     string
+		priority string
+		status   int
+	}{
+		{name: "low", priority: "1", status: http.StatusServiceUnavailable},
+		{name: "minimum", priority: "5", status: http.StatusOK},
+		{name: "high", priority: "9", status: http.StatusOK},
+		{name: "default", status: http.StatusServiceUnavailable},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(c.priority) > 0 {
+				r.Header.Set("X-Priority", c.priority)
+			}
+
+			rec := shedderUnderLoad(NewPriorityShedder(1, 5, HeaderPriority("X-Priority", 0)), r)
+			if rec.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, rec.Code)
+			}
+
+			if shed := rec.Header().Get("Retry-After") == "1"; shed != (c.status == http.StatusServiceUnavailable) {
+				t.Errorf("unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestPriorityShedderUnderThreshold(t *testing.T) {
+	h := NewPriorityShedder(1, 5, HeaderPriority("X-Priority", 0)).Handler(http.HandlerFunc(
+		func(http.ResponseWriter, *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected low priority admitted without load, got %d", rec.Code)
+	}
+}
+
+func TestPriorityShedderNilPriority(t *testing.T) {
+	for _, min := range []int{0, 1} {
+		min := min
+		t.Run(strconv.Itoa(min), func(t *testing.T) {
+			expected := http.StatusOK
+			if min > 0 {
+				expected = http.StatusServiceUnavailable
+			}
+
+			rec := shedderUnderLoad(NewPriorityShedder(1, min, nil), httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != expected {
+				t.Errorf("expected priority zero, answering %d, got %d", expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPathPriority(t *testing.T) {
+	p := PathPriority(map[string]int{"/api/": 5, "/api/admin/": 9}, 1)
+
+	cases := map[string]int{"/": 1, "/api/things": 5, "/api/admin/users": 9}
+
+	for path, expected := range cases {
+		if got := p(httptest.NewRequest(http.MethodGet, path, nil)); got != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NewRateLimiter returns a handler that allows each client limit requests per
+// interval.
+func NewRateLimiter(limit int, interval time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Interval: interval}
+}
+
+// RateLimiter is the handler responsible for rate limiting clients, rejecting
+// requests over the limit with 429 Too Many Requests. Each key has a token
+// bucket holding up to Limit tokens that refills at Limit tokens per Interval.
+type RateLimiter struct {
+	// Limit is the number of requests allowed per Interval, and the largest
+	// burst allowed at once.
+	Limit int
+	// Interval is the period over which Limit applies. Defaults to 1s.
+	Interval time.Duration
+	// Key returns the key requests are counted under. Defaults to the client
+	// IP of the remote address.
+	Key func(*http.Request) string
+	// TrustedProxyHeaders lists headers, such as X-Forwarded-For, trusted to
+	// carry the client IP for the default Key. Set it only behind a proxy that
+	// overwrites them, since clients can otherwise forge a new key per request.
+	// Of a list such as X-Forwarded-For, the entry appended by the nearest
+	// proxy is used. By default only the remote address is used.
+	TrustedProxyHeaders []string
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Handler implements the middleware interface.
+func (h *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := h.key(r)
+
+		if wait, ok := h.allow(key, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *RateLimiter) key(r *http.Request) string {
+	if h.Key != nil {
+		return h.Key(r)
+	}
+
+	return clientIP(r, h.TrustedProxyHeaders, 1)
+}
+
+func (h *RateLimiter) interval() time.Duration {
+	if h.Interval <= 0 {
+		return time.Second
+	}
+
+	return h.Interval
+}
+
+// allow takes a token from the bucket for key, reporting false and how long
+// until a token is available when the bucket is empty.
+func (h *RateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	interval := h.interval()
+	limit := float64(h.Limit)
+	rate := limit / float64(interval)
+
+	if h.buckets == nil {
+		h.buckets = map[string]*tokenBucket{}
+	}
+
+	h.sweep(now, interval)
+
+	b, ok := h.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit, last: now}
+		h.buckets[key] = b
+	}
+
+	b.tokens = math.Min(limit, b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		if rate <= 0 {
+			return interval, false
+		}
+
+		return time.Duration((1 - b.tokens) / rate), false
+	}
+
+	b.tokens--
+
+	return 0, true
+}
+
+// sweep drops, at most once per interval, the buckets idle for a whole
+// interval. Those have refilled completely, so forgetting them changes
+// nothing but bounds memory to the recently active keys.
+func (h *RateLimiter) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(h.lastSweep) < interval {
+		return
+	}
+
+	h.lastSweep = now
+
+	for key, b := range h.buckets {
+		if now.Sub(b.last) >= interval {
+			delete(h.buckets, key)
+		}
+	}
+}
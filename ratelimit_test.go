@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterLimit(t *testing.T) {
+	h := NewRateLimiter(2, time.Hour).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	h := NewRateLimiter(1, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+}
+
+func TestRateLimiterPerClient(t *testing.T) {
+	h := NewRateLimiter(1, time.Hour).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = addr
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", addr, w.Code)
+		}
+	}
+}
+
+func TestRateLimiterIgnoresForgedHeaders(t *testing.T) {
+	h := NewRateLimiter(1, time.Hour).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	allowed := 0
+
+	for i := 0; i < 5; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i))
+		r.Header.Set("X-Real-IP", "198.51.100."+strconv.Itoa(i))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code == http.StatusOK {
+			allowed++
+		}
+	}
+
+	if allowed != 1 {
+		t.Errorf("expected 1 request allowed, got %d", allowed)
+	}
+}
+
+func TestRateLimiterTrustedProxyHeaders(t *testing.T) {
+	rl := NewRateLimiter(1, time.Hour)
+	rl.TrustedProxyHeaders = []string{"X-Forwarded-For"}
+	h := rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("X-Forwarded-For", ip)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", ip, w.Code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+)
+
+// NewRecoverer returns a handler that recovers panics in the wrapped handler,
+// logging them through logger and responding with 500 Internal Server Error.
+// A panic after the response status was sent aborts the connection with
+// http.ErrAbortHandler instead.
+func NewRecoverer(logger *RequestResponseLogger) *Recoverer {
+	if logger == nil {
+		logger = MinimalLogger(os.Stderr)
+	}
+
+	return &Recoverer{logger: logger}
+}
+
+// Recoverer is the handler responsible for recovering panics.
+type Recoverer struct {
+	// JSON responds with a JSON error object, carrying the request ID when
+	// there is one, instead of a plain text body.
+	JSON bool
+	// PanicHandler, when set, writes the response in place of the default
+	// 500 Internal Server Error, once the panic has been logged.
+	PanicHandler func(w http.ResponseWriter, r *http.Request, v interface{})
+
+	logger *RequestResponseLogger
+}
+
+// Handler implements the middleware interface.
+func (h *Recoverer) Handler(next http.Handler) http.Handler {
+	h.logger.initialize()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			id, _ := GetRequestID(r.Context())
+			h.logger.logEvent(&LogEvent{
+				Kind:      "panic",
+				RequestID: id,
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Panic:     fmt.Sprint(v),
+				Stack:     string(debug.Stack()),
+			})
+
+			// Once the status is sent, no error response can follow, so the
+			// connection is aborted rather than sending a truncated body as
+			// complete.
+			if rw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+
+			if h.PanicHandler != nil {
+				h.PanicHandler(w, r, v)
+
+				return
+			}
+
+			h.respond(w, id)
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (h *Recoverer) respond(w http.ResponseWriter, id string) {
+	status := http.StatusInternalServerError
+
+	if !h.JSON {
+		http.Error(w, http.StatusText(status), status)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct { // nolint:errcheck
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
+	}{http.StatusText(status), id})
+}
+
+func (h *Recoverer) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
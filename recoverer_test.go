@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicking(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func recovered(rec *Recoverer) *httptest.ResponseRecorder {
+	h := NewRequestIDHandler(func() string { return "req-1" }).Handler(rec.Handler(http.HandlerFunc(panicking)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/crash", nil))
+
+	return w
+}
+
+func TestRecoverer(t *testing.T) {
+	var out bytes.Buffer
+
+	w := recovered(NewRecoverer(Logger(MinimalLevel, &out)))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+
+	if got := strings.TrimSpace(w.Body.String()); got != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("expected a plain text body, got %q", got)
+	}
+
+	logged := out.String()
+	for _, s := range []string{"(panic) [req-1] GET /crash: boom", "goroutine ", "recoverer_test.go"} {
+		if !strings.Contains(logged, s) {
+			t.Errorf("expected %q in the log, got:\n%s", s, logged)
+		}
+	}
+}
+
+func TestRecovererJSON(t *testing.T) {
+	rec := NewRecoverer(Logger(MinimalLevel, &bytes.Buffer{}))
+	rec.JSON = true
+
+	w := recovered(rec)
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON 500, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var body struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Error != http.StatusText(http.StatusInternalServerError) || body.RequestID != "req-1" {
+		t.Errorf("unexpected body %+v", body)
+	}
+}
+
+func TestRecovererPanicHandler(t *testing.T) {
+	var out bytes.Buffer
+
+	rec := NewRecoverer(Logger(MinimalLevel, &out))
+	rec.PanicHandler = func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		http.Error(w, "custom: "+v.(string), http.StatusServiceUnavailable)
+	}
+
+	w := recovered(rec)
+
+	if w.Code != http.StatusServiceUnavailable || strings.TrimSpace(w.Body.String()) != "custom: boom" {
+		t.Errorf("expected the custom response, got %d %q", w.Code, w.Body.String())
+	}
+
+	if !strings.Contains(out.String(), "(panic)") {
+		t.Errorf("expected the panic logged before the custom handler, got:\n%s", out.String())
+	}
+}
+
+func TestRecovererAbortHandler(t *testing.T) {
+	h := NewRecoverer(Logger(MinimalLevel, &bytes.Buffer{})).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-raised, got %v", v)
+		}
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecovererAfterWriteHeader(t *testing.T) {
+	cases := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+	}{
+		{name: "write header", write: func(w http.ResponseWriter) { w.WriteHeader(http.StatusAccepted) }},
+		{name: "write", write: func(w http.ResponseWriter) { w.Write([]byte("partial")) }}, // nolint:errcheck
+		{name: "flush", write: func(w http.ResponseWriter) { w.(http.Flusher).Flush() }},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			called := false
+			rec := NewRecoverer(Logger(MinimalLevel, &out))
+			rec.PanicHandler = func(http.ResponseWriter, *http.Request, interface{}) { called = true }
+
+			h := rec.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.write(w)
+				panic("boom")
+			}))
+
+			w := httptest.NewRecorder()
+
+			defer func() {
+				if v := recover(); v != http.ErrAbortHandler {
+					t.Errorf("expected http.ErrAbortHandler, got %v", v)
+				}
+
+				if called || strings.Contains(w.Body.String(), http.StatusText(http.StatusInternalServerError)) {
+					t.Errorf("expected no error response, got %d %q", w.Code, w.Body.String())
+				}
+
+				if !strings.Contains(out.String(), "(panic)") {
+					t.Errorf("expected the panic logged, got:\n%s", out.String())
+				}
+			}()
+
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	}
+}
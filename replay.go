@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"container/heap"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultSeenStoreSize = 100000
+
+// SeenStore records keys that have been seen recently.
+type SeenStore interface {
+	// Seen records key as seen for window and reports whether it had already
+	// been seen within a previous, unexpired window.
+	Seen(key string, window time.Duration) bool
+}
+
+// NewMemorySeenStore returns an in-memory SeenStore holding at most size keys.
+func NewMemorySeenStore(size int) *MemorySeenStore {
+	if size <= 0 {
+		size = defaultSeenStoreSize
+	}
+
+	return &MemorySeenStore{size: size, keys: map[string]struct{}{}}
+}
+
+// MemorySeenStore is a bounded in-memory SeenStore. Keys are kept in order of
+// expiry, so expired keys are dropped as they come due and, when the store is
+// full, the key closest to expiry is evicted, each in O(log n).
+type MemorySeenStore struct {
+	mu   sync.Mutex
+	size int
+	keys map[string]struct{}
+	heap seenHeap
+}
+
+type seenKey struct {
+	key     string
+	expires time.Time
+}
+
+// Seen implements SeenStore.
+func (s *MemorySeenStore) Seen(key string, window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for len(s.heap) > 0 && !now.Before(s.heap[0].expires) {
+		delete(s.keys, heap.Pop(&s.heap).(*seenKey).key)
+	}
+
+	if _, ok := s.keys[key]; ok {
+		return true
+	}
+
+	if len(s.heap) >= s.size {
+		delete(s.keys, heap.Pop(&s.heap).(*seenKey).key)
+	}
+
+	s.keys[key] = struct{}{}
+	heap.Push(&s.heap, &seenKey{key: key, expires: now.Add(window)})
+
+	return false
+}
+
+// seenHeap is a container/heap of keys ordered by expiry.
+type seenHeap []*seenKey
+
+func (h seenHeap) Len() int            { return len(h) }
+func (h seenHeap) Less(i, j int) bool  { return h[i].expires.Before(h[j].expires) }
+func (h seenHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *seenHeap) Push(x interface{}) { *h = append(*h, x.(*seenKey)) }
+
+func (h *seenHeap) Pop() interface{} {
+	old := *h
+	k := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return k
+}
+
+// ReplayProtection returns a handler that rejects requests whose key header,
+// X-Request-ID by default, was already seen within window, with 409 Conflict.
+// A nil store uses a default MemorySeenStore.
+func ReplayProtection(window time.Duration, store SeenStore) *ReplayHandler {
+	if store == nil {
+		store = NewMemorySeenStore(0)
+	}
+
+	return &ReplayHandler{Header: xRequestIDKey, window: window, store: store}
+}
+
+// ReplayHandler is the handler responsible for rejecting replayed requests.
+type ReplayHandler struct {
+	// Header names the request header carrying the unique key or nonce.
+	Header string
+	// RequireKey rejects requests without the key header with 400 Bad
+	// Request instead of passing them through.
+	RequireKey bool
+	Log        *log.Logger
+
+	window time.Duration
+	store  SeenStore
+}
+
+// Handler implements the middleware interface.
+func (h *ReplayHandler) Handler(next http.Handler) http.Handler {
+	logger := h.Log
+	if logger == nil {
+		logger = log.New(os.Stderr, " [replay protection] ", log.LstdFlags)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(h.Header)
+		if len(key) == 0 {
+			if h.RequireKey {
+				http.Error(w, "missing "+h.Header+" header", http.StatusBadRequest)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if h.store.Seen(key, h.window) {
+			logger.Printf("rejected replay key=%q method=%s path=%q", key, r.Method, r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
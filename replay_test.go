@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemorySeenStore(t *testing.T) {
+	s := NewMemorySeenStore(2)
+
+	if s.Seen("a", time.Hour) {
+		t.Error("a: unexpectedly seen on first use")
+	}
+
+	if !s.Seen("a", time.Hour) {
+		t.Error("a: expected seen on second use")
+	}
+
+	s.Seen("b", 2*time.Hour)
+	s.Seen("c", 3*time.Hour)
+
+	if s.Seen("a", time.Hour) {
+		t.Error("a: expected evicted as the key closest to expiry")
+	}
+}
+
+func TestMemorySeenStoreExpiry(t *testing.T) {
+	s := NewMemorySeenStore(0)
+
+	s.Seen("a", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if s.Seen("a", time.Hour) {
+		t.Error("a: expected expired")
+	}
+}
+
+func TestReplayProtection(t *testing.T) {
+	h := ReplayProtection(time.Hour, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusConflict} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(xRequestIDKey, "once")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
@@ -2,16 +2,23 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 )
 
-type requestIDKey string
+type contextKey string
 
 const (
-	requestIDKeyName requestIDKey = "x-request-id-key"
-	xRequestIDKey    string       = "X-Request-ID"
+	requestIDKeyName contextKey = "x-request-id-key"
+	xRequestIDKey    string     = "X-Request-ID"
 )
 
+// RequestIDContextKey is the context key under which the request ID is stored.
+const RequestIDContextKey = requestIDKeyName
+
 // WithRequestID adds a value for X-Request-ID into the context.
 func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKeyName, requestID)
@@ -25,26 +32,142 @@ func GetRequestID(ctx context.Context) (string, bool) {
 }
 
 // NewRequestIDHandler returns a handler that can inject X-Request-ID
-// header, or re-use an existing one.
+// header, or re-use an existing one. A nil generator defaults to NewUUID.
 func NewRequestIDHandler(generator func() string) *RequestIDHandler {
+	if generator == nil {
+		generator = NewUUID
+	}
+
+	return NewRequestIDHandlerFromRequest(ignoreRequest(generator))
+}
+
+// NewRequestIDHandlerFromRequest returns a RequestIDHandler whose generator
+// derives new request IDs from the request.
+func NewRequestIDHandlerFromRequest(generator func(*http.Request) string) *RequestIDHandler {
 	return &RequestIDHandler{generator: generator}
 }
 
+func ignoreRequest(generator func() string) func(*http.Request) string {
+	return func(*http.Request) string { return generator() }
+}
+
+// NewRequestIDHandlerWithUUID returns a RequestIDHandler that generates
+// random UUIDs.
+func NewRequestIDHandlerWithUUID() *RequestIDHandler {
+	return NewRequestIDHandler(NewUUID)
+}
+
+const maxRequestIDLength = 128
+
+// ValidRequestID reports whether id is a non-empty request ID of at most 128
+// characters drawn from letters, digits and "-_.:+/=", which are safe to log.
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("-_.:+/=", c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewUUID returns a random (version 4) UUID as defined by RFC 4122.
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 // RequestIDHandler is the handler responsible for X-Request-ID management.
 type RequestIDHandler struct {
-	generator func() string
+	// HeaderName is the header the request ID is read from and written to.
+	// Defaults to X-Request-ID.
+	HeaderName string
+	// Validate reports whether an incoming request ID is acceptable; others
+	// are replaced by a generated ID. Defaults to ValidRequestID.
+	Validate func(id string) bool
+	// Trailer additionally sends the request ID as a trailer, set once the
+	// wrapped handler returns.
+	Trailer bool
+	// TraceParent derives the request ID from the trace ID of the W3C
+	// traceparent header, starting a new trace when the header is missing or
+	// malformed, and adds the span to the context for GetTraceParent.
+	TraceParent bool
+
+	mu        sync.RWMutex
+	generator func(*http.Request) string
+}
+
+// SetGenerator replaces the generator used for new request IDs. It is safe to
+// call while requests are being served.
+func (h *RequestIDHandler) SetGenerator(generator func() string) {
+	h.SetRequestGenerator(ignoreRequest(generator))
+}
+
+// SetRequestGenerator replaces the generator used for new request IDs with
+// one that derives them from the request.
+func (h *RequestIDHandler) SetRequestGenerator(generator func(*http.Request) string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.generator = generator
+}
+
+func (h *RequestIDHandler) generate(r *http.Request) string {
+	h.mu.RLock()
+	generator := h.generator
+	h.mu.RUnlock()
+
+	return generator(r)
 }
 
 // Handler implements the middleware interface.
 func (h *RequestIDHandler) Handler(next http.Handler) http.Handler {
+	name := h.HeaderName
+	if len(name) == 0 {
+		name = xRequestIDKey
+	}
+
+	validate := h.Validate
+	if validate == nil {
+		validate = ValidRequestID
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var id string
-		if id = r.Header.Get(xRequestIDKey); len(id) == 0 {
-			id = h.generator()
-			r.Header.Set(xRequestIDKey, id)
+		id := r.Header.Get(name)
+
+		if h.TraceParent {
+			tp, err := ParseTraceParent(r.Header.Get(traceParentHeader))
+			if err != nil {
+				tp = NewTraceParent()
+			}
+
+			id = tp.TraceID
+			r.Header.Set(name, id)
+			r = r.WithContext(WithTraceParent(r.Context(), tp))
+		} else if !validate(id) {
+			id = h.generate(r)
+			r.Header.Set(name, id)
+		}
+		w.Header().Set(name, id)
+
+		if h.Trailer {
+			w.Header().Add("Trailer", name)
+			defer w.Header().Set(name, id)
 		}
-		w.Header().Add("Trailer", xRequestIDKey)
-		defer w.Header().Set(xRequestIDKey, id)
 
 		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
 	})
@@ -53,3 +176,39 @@ func (h *RequestIDHandler) Handler(next http.Handler) http.Handler {
 func (h *RequestIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
 	h.Handler(next).ServeHTTP(w, r)
 }
+
+// NewRequestIDRoundTripper returns an http.RoundTripper that propagates the
+// request ID in the request context to outgoing requests. A nil inner
+// defaults to http.DefaultTransport.
+func NewRequestIDRoundTripper(inner http.RoundTripper) *RequestIDRoundTripper {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+
+	return &RequestIDRoundTripper{inner: inner}
+}
+
+// RequestIDRoundTripper is an http.RoundTripper that sets the request ID
+// header on outgoing requests that do not already carry one.
+type RequestIDRoundTripper struct {
+	// HeaderName is the header the request ID is sent in. Defaults to
+	// X-Request-ID.
+	HeaderName string
+
+	inner http.RoundTripper
+}
+
+// RoundTrip fulfills the http.RoundTripper interface.
+func (t *RequestIDRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	name := t.HeaderName
+	if len(name) == 0 {
+		name = xRequestIDKey
+	}
+
+	if id, ok := GetRequestID(r.Context()); ok && len(r.Header.Get(name)) == 0 {
+		r = r.Clone(r.Context())
+		r.Header.Set(name, id)
+	}
+
+	return t.inner.RoundTrip(r)
+}
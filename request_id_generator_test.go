@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func generatedRequestID(h *RequestIDHandler, path string) string {
+	var id string
+
+	h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+
+	return id
+}
+
+func pathRequestID(r *http.Request) string {
+	return strings.ToLower(r.Method) + strings.ReplaceAll(r.URL.Path, "/", "-")
+}
+
+func TestRequestIDHandlerFromRequest(t *testing.T) {
+	h := NewRequestIDHandlerFromRequest(pathRequestID)
+
+	if got := generatedRequestID(h, "/users/42"); got != "get-users-42" {
+		t.Errorf("expected an ID derived from the path, got %q", got)
+	}
+}
+
+func TestRequestIDHandlerSetRequestGenerator(t *testing.T) {
+	h := NewRequestIDHandler(func() string { return "fixed" })
+
+	if got := generatedRequestID(h, "/a"); got != "fixed" {
+		t.Errorf("expected the original generator, got %q", got)
+	}
+
+	h.SetRequestGenerator(pathRequestID)
+
+	if got := generatedRequestID(h, "/a"); got != "get-a" {
+		t.Errorf("expected the request generator, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+)
+
+func TestRequestIDHandlerHeaderName(t *testing.T) {
+	const name = "X-Correlation-ID"
+
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithLogResponses(false), WithRequestIDHeader(name))
+
+	tmpl := template.Must(template.New("req").Funcs(l.FuncMap(MinimalLevel)).Parse(
+		"{{ requestid .request.Header }} {{ .requestid }}\n"))
+	if err := l.SetRequestTemplate(MinimalLevel, tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	rid := NewRequestIDHandler(func() string { return "generated" })
+	rid.HeaderName = name
+
+	h := rid.Handler(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(xRequestIDKey); len(got) > 0 {
+			t.Errorf("expected no %s header, got %q", xRequestIDKey, got)
+		}
+	})))
+
+	for in, expected := range map[string]string{"": "generated", "incoming-1": "incoming-1"} {
+		out.Reset()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if len(in) > 0 {
+			r.Header.Set(name, in)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get(name); got != expected {
+			t.Errorf("expected response header %s %q, got %q", name, expected, got)
+		}
+
+		if got := out.String(); got != expected+" "+expected+"\n" {
+			t.Errorf("expected the logged request ID %q, got %q", expected, got)
+		}
+	}
+}
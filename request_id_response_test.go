@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func requestIDResponse(t *testing.T, h *RequestIDHandler) *http.Response {
+	t.Helper()
+
+	ts := httptest.NewServer(h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok")) // nolint:errcheck
+	})))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func TestRequestIDResponseHeader(t *testing.T) {
+	resp := requestIDResponse(t, NewRequestIDHandler(func() string { return "id-1" }))
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if got := resp.Header.Get(xRequestIDKey); got != "id-1" {
+		t.Errorf("expected the %s response header, got %q", xRequestIDKey, got)
+	}
+
+	if len(resp.Trailer) > 0 {
+		t.Errorf("expected no trailers by default, got %v", resp.Trailer)
+	}
+}
+
+func TestRequestIDResponseTrailer(t *testing.T) {
+	h := NewRequestIDHandler(func() string { return "id-1" })
+	h.Trailer = true
+
+	resp := requestIDResponse(t, h)
+
+	if got := resp.Header.Get(xRequestIDKey); got != "id-1" {
+		t.Errorf("expected the %s response header, got %q", xRequestIDKey, got)
+	}
+
+	if got := resp.Trailer.Get(xRequestIDKey); got != "id-1" {
+		t.Errorf("expected the %s trailer, got %q", xRequestIDKey, got)
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// sentRequestID returns the name header sent by rt for a request whose
+// context carries id, if not empty, and whose header is preset.
+func sentRequestID(t *testing.T, rt *RequestIDRoundTripper, id, name, preset string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	if len(id) > 0 {
+		ctx = WithRequestID(ctx, id)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(preset) > 0 {
+		r.Header.Set(name, preset)
+	}
+
+	var sent string
+
+	rt.inner = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		sent = r.Header.Get(name)
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})
+
+	if _, err := rt.RoundTrip(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(preset) == 0 && len(r.Header.Get(name)) > 0 {
+		t.Error("expected the caller's request to be left unmodified")
+	}
+
+	return sent
+}
+
+func TestRequestIDRoundTripper(t *testing.T) {
+	if got := sentRequestID(t, NewRequestIDRoundTripper(nil), "req-1", xRequestIDKey, ""); got != "req-1" {
+		t.Errorf("expected the request ID from the context, got %q", got)
+	}
+
+	if got := sentRequestID(t, NewRequestIDRoundTripper(nil), "", xRequestIDKey, ""); len(got) > 0 {
+		t.Errorf("expected no request ID without one in the context, got %q", got)
+	}
+
+	if got := sentRequestID(t, NewRequestIDRoundTripper(nil), "req-1", xRequestIDKey, "preset"); got != "preset" {
+		t.Errorf("expected an existing header to be kept, got %q", got)
+	}
+}
+
+func TestRequestIDRoundTripperHeaderName(t *testing.T) {
+	rt := NewRequestIDRoundTripper(nil)
+	rt.HeaderName = "X-Correlation-ID"
+
+	if got := sentRequestID(t, rt, "req-1", rt.HeaderName, ""); got != "req-1" {
+		t.Errorf("expected the request ID in %s, got %q", rt.HeaderName, got)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUID(t *testing.T) {
+	const n = 1000
+
+	seen := make(map[string]bool, n)
+
+	for i := 0; i < n; i++ {
+		id := NewUUID()
+		if !uuidPattern.MatchString(id) {
+			t.Fatalf("malformed UUID %q", id)
+		}
+
+		if seen[id] {
+			t.Fatalf("duplicate UUID %q", id)
+		}
+
+		seen[id] = true
+	}
+}
+
+func TestRequestIDHandlerWithUUID(t *testing.T) {
+	var id string
+
+	h := NewRequestIDHandlerWithUUID().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !uuidPattern.MatchString(id) {
+		t.Errorf("expected a generated UUID, got %q", id)
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{name: "uuid", id: "0f8fad5b-d9cb-469f-a165-70867728950e", expected: true},
+		{name: "symbols", id: "a_b.c:d+e/f=", expected: true},
+		{name: "max length", id: strings.Repeat("a", 128), expected: true},
+		{name: "empty", id: "", expected: false},
+		{name: "over-long", id: strings.Repeat("a", 129), expected: false},
+		{name: "newline", id: "abc\nforged log line", expected: false},
+		{name: "control", id: "abc\x1b[31m", expected: false},
+		{name: "space", id: "a b", expected: false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := ValidRequestID(c.id); got != c.expected {
+				t.Errorf("expected %t, got %t", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestRequestIDHandlerRegeneratesInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		incoming string
+		expected string
+	}{
+		{name: "valid", incoming: "client-id-1", expected: "client-id-1"},
+		{name: "over-long", incoming: strings.Repeat("a", 200), expected: "generated"},
+		{name: "control characters", incoming: "id\r\nX-Injected: 1", expected: "generated"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var id string
+
+			h := NewRequestIDHandler(func() string { return "generated" }).Handler(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					id, _ = GetRequestID(r.Context())
+				}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header[http.CanonicalHeaderKey(xRequestIDKey)] = []string{c.incoming}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if id != c.expected {
+				t.Errorf("expected request ID %q, got %q", c.expected, id)
+			}
+
+			if got := w.Header().Get(xRequestIDKey); got != c.expected {
+				t.Errorf("expected response header %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestRequestIDHandlerCustomValidate(t *testing.T) {
+	var id string
+
+	rid := NewRequestIDHandler(func() string { return "generated" })
+	rid.Validate = func(id string) bool { return strings.HasPrefix(id, "req-") }
+
+	h := rid.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(xRequestIDKey, "other")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if id != "generated" {
+		t.Errorf("expected the custom validator to reject the ID, got %q", id)
+	}
+}
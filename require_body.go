@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// NewRequireBodyHandler returns a handler that rejects requests made with any
+// of the given methods when they carry no body.
+func NewRequireBodyHandler(methods ...string) *RequireBodyHandler {
+	return &RequireBodyHandler{Methods: methods}
+}
+
+// RequireBodyHandler is the handler responsible for rejecting requests that
+// are missing a required body with 400 Bad Request.
+type RequireBodyHandler struct {
+	Methods []string
+	// Require, when set, decides which requests must carry a body instead of
+	// Methods.
+	Require func(*http.Request) bool
+}
+
+// Handler implements the middleware interface.
+func (h *RequireBodyHandler) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.requires(r) && !peekBody(r) {
+			http.Error(w, "request body required", http.StatusBadRequest)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *RequireBodyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *RequireBodyHandler) requires(r *http.Request) bool {
+	if h.Require != nil {
+		return h.Require(r)
+	}
+
+	return matchAny(r.Method, h.Methods...)
+}
+
+// peekBody reports whether the request has a non-empty body. When the length
+// is not declared it reads a single byte, which is stitched back onto the body
+// so the handler still sees the full stream.
+func peekBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+
+	if r.ContentLength > 0 {
+		return true
+	}
+
+	var b [1]byte
+
+	n, _ := io.ReadFull(r.Body, b[:])
+	if n == 0 {
+		return false
+	}
+
+	r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(b[:n]), r.Body), Closer: r.Body}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireBodyHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		body     string
+		declared int64
+		status   int
+	}{
+		{name: "no body", method: http.MethodPost, declared: 0, status: http.StatusBadRequest},
+		{name: "empty undeclared", method: http.MethodPost, declared: -1, status: http.StatusBadRequest},
+		{name: "one byte undeclared", method: http.MethodPost, body: "x", declared: -1, status: http.StatusOK},
+		{name: "undeclared", method: http.MethodPut, body: "hello", declared: -1, status: http.StatusOK},
+		{name: "declared", method: http.MethodPost, body: "hello", declared: 5, status: http.StatusOK},
+		{name: "other method", method: http.MethodGet, declared: 0, status: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var got string
+
+			h := NewRequireBodyHandler(http.MethodPost, http.MethodPut).Handler(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					b, _ := ioutil.ReadAll(r.Body)
+					got = string(b)
+				}))
+
+			r := httptest.NewRequest(c.method, "/", nil)
+			if c.declared != 0 {
+				r.Body = ioutil.NopCloser(strings.NewReader(c.body))
+			}
+
+			r.ContentLength = c.declared
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, rec.Code)
+			}
+
+			if c.status == http.StatusOK && got != c.body {
+				t.Errorf("expected the whole body, peeked byte included, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequireBodyHandlerPredicate(t *testing.T) {
+	h := &RequireBodyHandler{Require: func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/upload") }}
+	handler := h.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for path, expected := range map[string]int{"/upload": http.StatusBadRequest, "/other": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+
+		if rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, rec.Code)
+		}
+	}
+}
+
+func TestRequireBodyHandlerServer(t *testing.T) {
+	ts := httptest.NewServer(NewRequireBodyHandler(http.MethodPost).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			w.Write(b) // nolint:errcheck
+		})))
+	defer ts.Close()
+
+	for body, expected := range map[string]int{"": http.StatusBadRequest, "x": http.StatusOK} {
+		// A reader of unknown length is sent chunked, without a Content-Length.
+		res, err := http.Post(ts.URL, "text/plain", ioutil.NopCloser(strings.NewReader(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		echoed, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close() // nolint:errcheck
+
+		if res.StatusCode != expected {
+			t.Errorf("%q: expected %d, got %d", body, expected, res.StatusCode)
+		}
+
+		if expected == http.StatusOK && string(echoed) != body {
+			t.Errorf("expected the body echoed, got %q", echoed)
+		}
+	}
+}
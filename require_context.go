@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// RequireContext returns a handler that reports requests missing a value for
+// any of the given context keys, such as RequestIDContextKey, which usually
+// means the middleware providing them was not installed, or was installed
+// after this one.
+func RequireContext(keys ...interface{}) *ContextRequirement {
+	return &ContextRequirement{keys: keys}
+}
+
+// ContextRequirement is the handler responsible for asserting middleware
+// preconditions during development.
+type ContextRequirement struct {
+	// Strict panics on a missing value instead of logging a warning.
+	Strict bool
+	Log    *log.Logger
+
+	keys []interface{}
+}
+
+// Handler implements the middleware interface.
+func (h *ContextRequirement) Handler(next http.Handler) http.Handler {
+	logger := h.Log
+	if logger == nil {
+		logger = log.New(os.Stderr, " [require context] ", log.LstdFlags)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, key := range h.keys {
+			if r.Context().Value(key) != nil {
+				continue
+			}
+
+			msg := fmt.Sprintf("WARNING: missing context value %v for %s %s; check middleware ordering", key, r.Method, r.URL.Path)
+			if h.Strict {
+				panic(msg)
+			}
+
+			logger.Print(msg)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ContextRequirement) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type externalKey struct{}
+
+func requireContextWarnings(h *ContextRequirement, outer ...Middleware) string {
+	var warnings bytes.Buffer
+
+	h.Log = log.New(&warnings, "", 0)
+
+	handler := ChainMiddleware(append(outer, h)...)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	return warnings.String()
+}
+
+func TestRequireContextMissing(t *testing.T) {
+	got := requireContextWarnings(RequireContext(RequestIDContextKey, FanoutContextKey))
+
+	for _, want := range []string{"x-request-id-key for GET /things", "fanout-counter-key for GET /things"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the warnings, got %q", want, got)
+		}
+	}
+}
+
+func TestRequireContextProvided(t *testing.T) {
+	ids := NewRequestIDHandler(func() string { return "abc" })
+	ids.TraceParent = true
+
+	l := Logger(MinimalLevel, ioutil.Discard)
+	c := &TrafficClassifier{}
+
+	req := RequireContext(RequestIDContextKey, TraceParentContextKey, FanoutContextKey, LogLevelContextKey,
+		TrafficClassContextKey)
+
+	if got := requireContextWarnings(req, ids, l, c); len(got) > 0 {
+		t.Errorf("expected no warnings, got %q", got)
+	}
+}
+
+func TestRequireContextExternalKey(t *testing.T) {
+	got := requireContextWarnings(RequireContext(externalKey{}))
+	if !strings.Contains(got, "missing context value {}") {
+		t.Errorf("expected a warning for the external key, got %q", got)
+	}
+}
+
+func TestRequireContextStrict(t *testing.T) {
+	defer func() {
+		if p, _ := recover().(string); !strings.Contains(p, "log-level-key") {
+			t.Errorf("expected a panic naming the key, got %q", p)
+		}
+	}()
+
+	h := RequireContext(LogLevelContextKey)
+	h.Strict = true
+
+	requireContextWarnings(h)
+}
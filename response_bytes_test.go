@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+const responseBytesSize = 12345
+
+func knownSizeHandler(w http.ResponseWriter, r *http.Request) {
+	body := []byte(strings.Repeat("x", responseBytesSize))
+
+	// Written in chunks, flushing between them, as a streaming handler would.
+	for len(body) > 0 {
+		n := 1000
+		if n > len(body) {
+			n = len(body)
+		}
+
+		w.Write(body[:n]) // nolint:errcheck
+		w.(http.Flusher).Flush()
+
+		body = body[n:]
+	}
+}
+
+func TestResponseWriterBytes(t *testing.T) {
+	rw := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+	knownSizeHandler(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rw.written(); got != responseBytesSize {
+		t.Errorf("expected %d bytes, got %d", responseBytesSize, got)
+	}
+}
+
+func TestLoggerResponseBytes(t *testing.T) {
+	events := make(chan LogEvent, 4)
+
+	l := Logger(MinimalLevel, ioutil.Discard)
+	l.Events = events
+
+	l.Handler(http.HandlerFunc(knownSizeHandler)).ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := responseEvent(t, events).Bytes; got != responseBytesSize {
+		t.Errorf("expected %d bytes, got %d", responseBytesSize, got)
+	}
+}
+
+func TestLoggerResponseBytesTemplate(t *testing.T) {
+	var out bytes.Buffer
+
+	l := Logger(MinimalLevel, &out, WithLogRequests(false))
+	if err := l.SetResponseTemplate(MinimalLevel, template.Must(template.New("resp").Parse("{{ .bytes }}\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Handler(http.HandlerFunc(knownSizeHandler)).ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := out.String(); got != "12345\n" {
+		t.Errorf("expected %d bytes, got %q", responseBytesSize, got)
+	}
+}
+
+func TestMetricsResponseBytes(t *testing.T) {
+	reg := NewMetricsRegistry()
+
+	NewMetrics(reg).Handler(http.HandlerFunc(knownSizeHandler)).ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if text := metricsText(reg); !strings.Contains(text, `http_response_size_bytes_total{method="GET"} 12345`) {
+		t.Errorf("expected the byte count, got:\n%s", text)
+	}
+}
+
+func TestAccessLogResponseBytes(t *testing.T) {
+	m := accessLogLine(t, NewAccessLog(nil), knownSizeHandler, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if size := m[9]; size != "12345" {
+		t.Errorf("expected %d bytes, got %q", responseBytesSize, size)
+	}
+}
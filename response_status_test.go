@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func writeOnly(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("body")) // nolint:errcheck
+}
+
+func doubleWriteHeader(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func TestResponseWriterWriteWithoutWriteHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: rec}
+
+	writeOnly(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rw.status != http.StatusOK || rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d and %d", rw.status, rec.Code)
+	}
+}
+
+func TestResponseWriterDoubleWriteHeader(t *testing.T) {
+	var warnings bytes.Buffer
+
+	rec := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: rec, log: log.New(&warnings, "", 0)}
+
+	doubleWriteHeader(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rw.status != http.StatusAccepted || rec.Code != http.StatusAccepted {
+		t.Errorf("expected the first status kept, got %d and %d", rw.status, rec.Code)
+	}
+
+	if got := warnings.String(); !strings.Contains(got, "superfluous WriteHeader(500) call from") ||
+		!strings.Contains(got, "doubleWriteHeader") {
+		t.Errorf("expected a warning naming the caller, got %q", got)
+	}
+}
+
+func TestResponseWriterInformational(t *testing.T) {
+	rw := &responseWriter{ResponseWriter: httptest.NewRecorder(), log: log.New(ioutil.Discard, "", 0)}
+
+	rw.WriteHeader(http.StatusEarlyHints)
+	rw.WriteHeader(http.StatusNoContent)
+
+	if rw.status != http.StatusNoContent {
+		t.Errorf("expected the final status after an informational one, got %d", rw.status)
+	}
+}
+
+func TestLoggerCapturedStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected float64
+	}{
+		{name: "write without WriteHeader", handler: writeOnly, expected: http.StatusOK},
+		{name: "double WriteHeader", handler: doubleWriteHeader, expected: http.StatusAccepted},
+		{name: "no response", handler: func(http.ResponseWriter, *http.Request) {}, expected: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			l := Logger(MinimalLevel, &out, WithFormat(JSONFormat))
+			l.Log = log.New(ioutil.Discard, "", 0)
+
+			l.Handler(c.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := jsonEvent(t, out.String(), "response")["status"]; got != c.expected {
+				t.Errorf("expected status %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// responseWriter wraps an http.ResponseWriter, recording the status code and
+// the number of body bytes written. Like net/http, it takes a Write before any
+// WriteHeader as an implicit 200 OK, and ignores WriteHeader once the status
+// is set, logging a warning to log, or the standard logger when nil.
+type responseWriter struct {
+	bytes int64 // accessed atomically; kept first for 64-bit alignment
+
+	http.ResponseWriter
+	status int
+	log    *log.Logger
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if informational(code) {
+		w.ResponseWriter.WriteHeader(code)
+
+		return
+	}
+
+	if w.status != 0 {
+		w.warnf("superfluous WriteHeader(%d) call from %s; status already %d", code, callerName(1), w.status)
+
+		return
+	}
+
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(&w.bytes, int64(n))
+
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying writer does. Like a
+// Write, flushing before any WriteHeader sends an implicit 200 OK.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying writer does. A hijacked
+// connection that never wrote a status is recorded as 101 Switching Protocols.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+// informational reports whether code is an informational status which, unlike
+// 101 Switching Protocols, precedes the final status.
+func informational(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
+
+func (w *responseWriter) warnf(format string, args ...interface{}) {
+	if w.log != nil {
+		w.log.Printf(format, args...)
+
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+func (w *responseWriter) written() int64 {
+	return atomic.LoadInt64(&w.bytes)
+}
+
+// captureWriter writes through to the underlying http.ResponseWriter as data
+// arrives, keeping a copy of the body for logging.
+// At most limit bytes are kept, unless limit is zero, and none when discard is
+// set. Server-sent event streams are not captured at all, and are flushed
+// after every write.
+//
+// When held is set, the status and body are instead held back, for the owner
+// to inspect before calling release, until the handler flushes, starts a
+// server-sent event stream or writes more than limit bytes; the writer then
+// releases them itself and carries on as above.
+type captureWriter struct {
+	responseWriter
+	body     bytes.Buffer
+	limit    int64
+	discard  bool
+	hijacked bool
+	stream   bool
+	held     bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	return &captureWriter{responseWriter: responseWriter{ResponseWriter: w}, limit: limit}
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	w.detectStream()
+
+	if w.held && !informational(code) {
+		if w.status != 0 {
+			w.warnf("superfluous WriteHeader(%d) call from %s; status already %d", code, callerName(1), w.status)
+
+			return
+		}
+
+		w.status = code
+
+		return
+	}
+
+	w.responseWriter.WriteHeader(code)
+}
+
+// detectStream switches to pass-through once the handler starts a
+// text/event-stream response.
+func (w *captureWriter) detectStream() {
+	if w.status == 0 && len(w.Header().Get("Content-Type")) > 0 && HasContentType(w.Header(), "text/event-stream") {
+		w.stream = true
+		w.held = false
+	}
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.detectStream()
+
+	if w.held {
+		if w.limit <= 0 || int64(w.body.Len()+len(p)) <= w.limit {
+			if w.status == 0 {
+				w.status = http.StatusOK
+			}
+
+			return w.body.Write(p)
+		}
+
+		w.release()
+	}
+
+	n, err := w.responseWriter.Write(p)
+	if w.stream {
+		w.Flush()
+
+		return n, err
+	}
+
+	if !w.hijacked && !w.discard {
+		keep := p[:n]
+		if room := w.limit - int64(w.body.Len()); w.limit > 0 && int64(len(keep)) > room {
+			keep = keep[:room]
+		}
+
+		w.body.Write(keep)
+	}
+
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying writer does, releasing
+// anything held first.
+func (w *captureWriter) Flush() {
+	w.release()
+	w.responseWriter.Flush()
+}
+
+// release writes out the held status and body, if any, and stops holding.
+// The body stays captured.
+func (w *captureWriter) release() {
+	if !w.held {
+		return
+	}
+
+	w.held = false
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+	w.status = status
+
+	n, _ := w.ResponseWriter.Write(w.body.Bytes())
+	atomic.AddInt64(&w.bytes, int64(n))
+}
+
+// Hijack implements http.Hijacker when the underlying writer does. Once the
+// connection is hijacked nothing further is captured.
+func (w *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		w.held = false
+		w.body.Reset()
+	}
+
+	return conn, rw, err
+}
+
+// result returns the captured response. A hijacked connection that never
+// wrote a status is reported as 101 Switching Protocols.
+func (w *captureWriter) result() *http.Response {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+		if w.hijacked {
+			status = http.StatusSwitchingProtocols
+		}
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Header:        w.Header().Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(w.body.Bytes())),
+		ContentLength: w.written(),
+	}
+}
+
+// countingBody wraps a request body, recording the number of bytes read.
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+
+	return n, err
+}
+
+// teeBody wraps a request body, keeping a copy of up to limit bytes of what is
+// read from it, or all of it when limit is zero.
+type teeBody struct {
+	io.ReadCloser
+	limit int64
+
+	mu    sync.Mutex // the transport may still be reading when the copy is taken
+	buf   bytes.Buffer
+	total int64
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keep := p[:n]
+	if room := b.limit - int64(b.buf.Len()); b.limit > 0 && int64(len(keep)) > room {
+		keep = keep[:room]
+	}
+
+	b.buf.Write(keep)
+	b.total += int64(n)
+
+	return n, err
+}
+
+// clamp lowers the capture limit to n, so that the log never shows more of the
+// body than a downstream size limit lets through.
+func (b *teeBody) clamp(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > 0 && (b.limit <= 0 || n < b.limit) {
+		b.limit = n
+	}
+}
+
+// logged returns a copy of the body read so far, along with the number of
+// bytes left out of it, or -1 when that is unknown.
+func (b *teeBody) logged(contentLength int64) ([]byte, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	body := append([]byte(nil), b.buf.Bytes()...)
+
+	switch {
+	case contentLength > int64(len(body)):
+		return body, contentLength - int64(len(body))
+	case b.total > int64(len(body)):
+		return body, -1
+	}
+
+	return body, 0
+}
+
+// callerName returns the function skip frames above the caller of
+// callerName, or "unknown".
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		return fn.Name()
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoUpgrade hijacks the connection, switches protocols and echoes a line.
+func echoUpgrade(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+
+			return
+		}
+		defer conn.Close()
+
+		// nolint:errcheck
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush() // nolint:errcheck
+
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line) // nolint:errcheck
+		rw.Flush()           // nolint:errcheck
+	}
+}
+
+// upgradeEcho serves h, upgrades a connection to it, sending body with the
+// request, and checks the echo, returning once h has returned.
+func upgradeEcho(t *testing.T, h http.Handler, body string) {
+	t.Helper()
+
+	done := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// nolint:errcheck
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nUpgrade: echo\r\nConnection: Upgrade\r\nContent-Length: %d\r\n\r\n%s",
+		len(body), body)
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	conn.Write([]byte("ping\n")) // nolint:errcheck
+
+	if line, err := br.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("expected the echo over the hijacked connection, got %q, %v", line, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handler to return")
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	var out syncBuffer
+
+	reg := NewMetricsRegistry()
+
+	cases := []struct {
+		name string
+		mw   Middleware
+	}{
+		{name: "metrics", mw: NewMetrics(reg)},
+		{name: "access log", mw: NewAccessLog(&out)},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			upgradeEcho(t, c.mw.Handler(echoUpgrade(t)), "")
+		})
+	}
+
+	if !strings.Contains(out.String(), `"POST / HTTP/1.1" 101 -`) {
+		t.Errorf("expected the upgrade in the access log, got %q", out.String())
+	}
+
+	if !strings.Contains(metricsText(reg), `http_requests_total{method="POST",code="1xx"} 1`) {
+		t.Errorf("expected the upgrade counted, got:\n%s", metricsText(reg))
+	}
+}
+
+func TestResponseWriterHijackUnsupported(t *testing.T) {
+	rw := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+
+	if _, _, err := rw.Hijack(); err != http.ErrNotSupported {
+		t.Errorf("expected http.ErrNotSupported, got %v", err)
+	}
+
+	if rw.status != 0 {
+		t.Errorf("expected no status recorded, got %d", rw.status)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.21
+// +build go1.21
+
+package middleware
+
+import (
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// NewSlogLogger returns a logger that emits each entry as a structured record
+// to logger instead of rendering the level templates to Writer. Entries at
+// minimal and normal detail are logged at slog.LevelInfo, verbose and debug at
+// slog.LevelDebug, and failures at slog.LevelError.
+func NewSlogLogger(level DetailLevel, logger *slog.Logger, opts ...Option) *RequestResponseLogger {
+	l := Logger(level, ioutil.Discard, opts...)
+	l.sink = func(ev *LogEvent, level DetailLevel) {
+		logger.LogAttrs(context.Background(), slogLevel(ev, level), ev.Kind, slogAttrs(ev)...)
+	}
+
+	return l
+}
+
+func slogLevel(ev *LogEvent, level DetailLevel) slog.Level {
+	switch {
+	case ev.Status >= http.StatusBadRequest, len(ev.Panic) > 0, len(ev.Error) > 0:
+		return slog.LevelError
+	case level >= VerboseLevel:
+		return slog.LevelDebug
+	}
+
+	return slog.LevelInfo
+}
+
+func slogAttrs(ev *LogEvent) []slog.Attr {
+	fields := ev.fields()
+	attrs := make([]slog.Attr, 0, len(fields))
+
+	for _, f := range fields[1:] {
+		switch v := f.value.(type) {
+		case http.Header:
+			group := make([]interface{}, 0, len(v))
+			for _, k := range sortedHeaderKeys(v) {
+				group = append(group, slog.String(k, joinHeaderValues(v[k])))
+			}
+
+			attrs = append(attrs, slog.Group(f.key, group...))
+		case time.Duration:
+			attrs = append(attrs, slog.Duration(f.key, v))
+		default:
+			attrs = append(attrs, slog.Any(f.key, v))
+		}
+	}
+
+	return attrs
+}
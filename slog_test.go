@@ -0,0 +1,130 @@
+//go:build go1.21
+// +build go1.21
+
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// captureHandler is an slog.Handler recording the records it handles.
+type captureHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, r)
+
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler      { return h }
+
+func recordAttrs(r slog.Record) map[string]slog.Value {
+	attrs := map[string]slog.Value{}
+
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+
+		return true
+	})
+
+	return attrs
+}
+
+func TestSlogLogger(t *testing.T) {
+	capture := &captureHandler{}
+
+	l := NewSlogLogger(NormalLevel, slog.New(capture))
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/things", nil)
+	r = r.WithContext(WithRequestID(r.Context(), "req-1"))
+	r.Header.Set("Authorization", "Bearer secret")
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(capture.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(capture.records))
+	}
+
+	req, resp := capture.records[0], capture.records[1]
+
+	if req.Message != "request" || req.Level != slog.LevelInfo {
+		t.Errorf("unexpected request record %q at %v", req.Message, req.Level)
+	}
+
+	attrs := recordAttrs(req)
+	if attrs["request_id"].String() != "req-1" || attrs["method"].String() != http.MethodPost ||
+		attrs["path"].String() != "/things" {
+		t.Errorf("unexpected request attributes %v", attrs)
+	}
+
+	var auth string
+	for _, a := range attrs["headers"].Group() {
+		if a.Key == "Authorization" {
+			auth = a.Value.String()
+		}
+	}
+
+	if auth != "[redacted]" {
+		t.Errorf("expected Authorization redacted, got %q", auth)
+	}
+
+	if resp.Message != "response" || resp.Level != slog.LevelInfo {
+		t.Errorf("unexpected response record %q at %v", resp.Message, resp.Level)
+	}
+
+	attrs = recordAttrs(resp)
+	if attrs["status"].Int64() != http.StatusCreated {
+		t.Errorf("expected status %d, got %v", http.StatusCreated, attrs["status"])
+	}
+
+	if attrs["duration"].Kind() != slog.KindDuration || attrs["duration"].Duration() <= 0 {
+		t.Errorf("expected a duration, got %v", attrs["duration"])
+	}
+}
+
+func TestSlogLoggerLevels(t *testing.T) {
+	cases := []struct {
+		level    DetailLevel
+		status   int
+		expected slog.Level
+	}{
+		{level: MinimalLevel, status: http.StatusOK, expected: slog.LevelInfo},
+		{level: VerboseLevel, status: http.StatusOK, expected: slog.LevelDebug},
+		{level: DebugLevel, status: http.StatusOK, expected: slog.LevelDebug},
+		{level: MinimalLevel, status: http.StatusInternalServerError, expected: slog.LevelError},
+	}
+
+	for _, c := range cases {
+		capture := &captureHandler{}
+
+		h := NewSlogLogger(c.level, slog.New(capture)).Handler(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(c.status) }))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if n := len(capture.records); n == 0 {
+			t.Fatalf("expected records at %v", c.level)
+		}
+
+		if got := capture.records[len(capture.records)-1].Level; got != c.expected {
+			t.Errorf("expected a %d response at %v to log at %v, got %v", c.status, c.level, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultSlowRequests = 20
+
+// SlowRequest describes a request retained by a SlowRequestTracker.
+type SlowRequest struct {
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Status    int           `json:"status"`
+	RequestID string        `json:"request_id,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	Time      time.Time     `json:"time"`
+}
+
+// NewSlowRequestTracker returns a tracker that retains the n slowest requests
+// seen within maxAge. A zero maxAge retains requests indefinitely.
+func NewSlowRequestTracker(n int, maxAge time.Duration) *SlowRequestTracker {
+	if n <= 0 {
+		n = defaultSlowRequests
+	}
+
+	return &SlowRequestTracker{size: n, maxAge: maxAge}
+}
+
+// SlowRequestTracker is responsible for tracking the slowest recent requests,
+// as observed by a RequestResponseLogger.
+type SlowRequestTracker struct {
+	mu       sync.Mutex
+	size     int
+	maxAge   time.Duration
+	requests slowHeap
+}
+
+// Record considers req for retention among the slowest requests.
+func (h *SlowRequestTracker) Record(req SlowRequest) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire(time.Now())
+
+	if len(h.requests) < h.size {
+		heap.Push(&h.requests, req)
+
+		return
+	}
+
+	if req.Duration > h.requests[0].Duration {
+		h.requests[0] = req
+		heap.Fix(&h.requests, 0)
+	}
+}
+
+// Slowest returns the retained requests, slowest first.
+func (h *SlowRequestTracker) Slowest() []SlowRequest {
+	h.mu.Lock()
+	h.expire(time.Now())
+	res := append([]SlowRequest(nil), h.requests...)
+	h.mu.Unlock()
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Duration > res[j].Duration })
+
+	return res
+}
+
+func (h *SlowRequestTracker) expire(now time.Time) {
+	if h.maxAge <= 0 {
+		return
+	}
+
+	kept := h.requests[:0]
+
+	for _, req := range h.requests {
+		if now.Sub(req.Time) < h.maxAge {
+			kept = append(kept, req)
+		}
+	}
+
+	if len(kept) != len(h.requests) {
+		h.requests = kept
+		heap.Init(&h.requests)
+	}
+}
+
+// Observe considers an exchange for retention among the slowest requests.
+// Add it to the Observers of a RequestResponseLogger to track the requests it
+// serves.
+func (h *SlowRequestTracker) Observe(o Observation) {
+	h.Record(SlowRequest{
+		Method:    o.Method,
+		Path:      o.Path,
+		Status:    o.Status,
+		RequestID: o.RequestID,
+		Duration:  o.Duration,
+		Time:      o.Start,
+	})
+}
+
+// DebugHandler returns an http.Handler reporting the slowest requests as JSON
+// at `/debug/slow`.
+func (h *SlowRequestTracker) DebugHandler() http.Handler {
+	m := http.NewServeMux()
+
+	m.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(h.Slowest()); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+	})
+
+	return m
+}
+
+// slowHeap is a min-heap of requests ordered by duration, so the fastest of
+// the retained requests is the first to be replaced.
+type slowHeap []SlowRequest
+
+func (s slowHeap) Len() int            { return len(s) }
+func (s slowHeap) Less(i, j int) bool  { return s[i].Duration < s[j].Duration }
+func (s slowHeap) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *slowHeap) Push(x interface{}) { *s = append(*s, x.(SlowRequest)) }
+
+func (s *slowHeap) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+
+	return x
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestTrackerObservesLogger(t *testing.T) {
+	events := make(chan LogEvent, 4)
+	tracker := NewSlowRequestTracker(0, 0)
+
+	l := Logger(MinimalLevel, ioutil.Discard)
+	l.Events = events
+	l.Observers = append(l.Observers, tracker.Observe)
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(durationTestSleep)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/brew", nil)
+	r = r.WithContext(WithRequestID(r.Context(), "abc"))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	ev := responseEvent(t, events)
+
+	slowest := tracker.Slowest()
+	if len(slowest) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(slowest))
+	}
+
+	got := slowest[0]
+	if got.Duration != ev.Duration || got.Status != http.StatusTeapot || got.Path != "/brew" || got.RequestID != "abc" {
+		t.Errorf("expected the logged exchange, taking %v, got %+v", ev.Duration, got)
+	}
+}
+
+func TestSlowRequestTrackerObservesSuppressed(t *testing.T) {
+	tracker := NewSlowRequestTracker(0, 0)
+
+	var out syncBuffer
+
+	l := Logger(MinimalLevel, &out)
+	l.SuppressStatuses = []int{http.StatusNotModified}
+	l.Observers = append(l.Observers, tracker.Observe)
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if out.String() != "" {
+		t.Errorf("expected nothing logged, got %q", out.String())
+	}
+
+	if got := tracker.Slowest(); len(got) != 1 || got[0].Status != http.StatusNotModified {
+		t.Errorf("expected the suppressed exchange observed, got %+v", got)
+	}
+}
+
+func TestSlowRequestTrackerRetention(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name     string
+		maxAge   time.Duration
+		observed []Observation
+		expected []time.Duration
+	}{
+		{
+			name: "slowest kept",
+			observed: []Observation{
+				{Duration: 1, Start: now},
+				{Duration: 3, Start: now},
+				{Duration: 2, Start: now},
+			},
+			expected: []time.Duration{3, 2},
+		},
+		{
+			name:   "expired dropped",
+			maxAge: time.Minute,
+			observed: []Observation{
+				{Duration: 3, Start: now.Add(-time.Hour)},
+				{Duration: 1, Start: now},
+			},
+			expected: []time.Duration{1},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tracker := NewSlowRequestTracker(2, c.maxAge)
+
+			for _, o := range c.observed {
+				tracker.Observe(o)
+			}
+
+			got := tracker.Slowest()
+			if len(got) != len(c.expected) {
+				t.Fatalf("expected %d requests, got %+v", len(c.expected), got)
+			}
+
+			for i, d := range c.expected {
+				if got[i].Duration != d {
+					t.Errorf("expected %v at %d, got %v", d, i, got[i].Duration)
+				}
+			}
+		})
+	}
+}
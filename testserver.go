@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// TestServerOption configures the middleware stack built by NewTestServer.
+type TestServerOption func(*testServerConfig)
+
+type testServerConfig struct {
+	level     DetailLevel
+	requestID bool
+	recover   bool
+	generator func() string
+	configure []func(*RequestResponseLogger)
+}
+
+// WithTestLevel sets the logging detail level. Defaults to VerboseLevel.
+func WithTestLevel(level DetailLevel) TestServerOption {
+	return func(c *testServerConfig) { c.level = level }
+}
+
+// WithoutRequestID omits the request ID middleware.
+func WithoutRequestID() TestServerOption {
+	return func(c *testServerConfig) { c.requestID = false }
+}
+
+// WithoutRecovery omits the panic recovery middleware.
+func WithoutRecovery() TestServerOption {
+	return func(c *testServerConfig) { c.recover = false }
+}
+
+// WithTestRequestIDGenerator sets the request ID generator. Defaults to
+// sequential IDs of the form "test-1".
+func WithTestRequestIDGenerator(generator func() string) TestServerOption {
+	return func(c *testServerConfig) { c.generator = generator }
+}
+
+// WithTestLogger applies additional configuration to the logger.
+func WithTestLogger(configure func(*RequestResponseLogger)) TestServerOption {
+	return func(c *testServerConfig) { c.configure = append(c.configure, configure) }
+}
+
+// NewTestServer starts an httptest.Server running handler behind the
+// package middleware: request ID, logging and panic recovery, outermost first.
+// Log output is captured in the returned TestLog. The response entry of a
+// request may be written after the client has read the response, so close the
+// server before reading the log to be sure it holds every entry.
+func NewTestServer(handler http.Handler, opts ...TestServerOption) (*httptest.Server, *TestLog) {
+	var seq int64
+
+	cfg := &testServerConfig{
+		level:     VerboseLevel,
+		requestID: true,
+		recover:   true,
+		generator: func() string { return "test-" + strconv.FormatInt(atomic.AddInt64(&seq, 1), 10) },
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	logs := &TestLog{}
+	logger := Logger(cfg.level, logs)
+
+	for _, configure := range cfg.configure {
+		configure(logger)
+	}
+
+	if cfg.recover {
+		handler = NewRecoverer(logger).Handler(handler)
+	}
+
+	handler = logger.Handler(handler)
+
+	if cfg.requestID {
+		handler = NewRequestIDHandler(cfg.generator).Handler(handler)
+	}
+
+	return httptest.NewServer(handler), logs
+}
+
+// TestLog is the log output captured by NewTestServer, safe for reading
+// while the server writes to it.
+type TestLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *TestLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.buf.Write(p)
+}
+
+// String returns the output captured so far.
+func (l *TestLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.buf.String()
+}
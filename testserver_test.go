@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testServerGet(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func TestNewTestServer(t *testing.T) {
+	ts, logs := NewTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := GetRequestID(r.Context())
+		w.Write([]byte(id)) // nolint:errcheck
+	}))
+
+	resp := testServerGet(t, ts.URL+"/hello")
+
+	ts.Close()
+
+	if got := resp.Header.Get(xRequestIDKey); got != "test-1" {
+		t.Errorf("expected request ID test-1, got %q", got)
+	}
+
+	out := logs.String()
+	for _, want := range []string{"[test-1]", "GET /hello", "BEGIN REQUEST", "200 OK"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the log:\n%s", want, out)
+		}
+	}
+}
+
+func TestNewTestServerRecovers(t *testing.T) {
+	ts, logs := NewTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), WithTestLevel(MinimalLevel))
+
+	resp := testServerGet(t, ts.URL)
+
+	ts.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", resp.StatusCode)
+	}
+
+	if out := logs.String(); !strings.Contains(out, "boom") || strings.Contains(out, "BEGIN REQUEST") {
+		t.Errorf("expected the panic at minimal detail in the log:\n%s", out)
+	}
+}
+
+func TestNewTestServerOptions(t *testing.T) {
+	ts, logs := NewTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetRequestID(r.Context()); ok {
+			t.Error("expected no request ID")
+		}
+	}), WithoutRequestID(), WithTestLogger(func(l *RequestResponseLogger) {
+		l.SkipPaths = []string{"/skipped"}
+	}))
+
+	testServerGet(t, ts.URL+"/skipped")
+	ts.Close()
+
+	if out := logs.String(); len(out) > 0 {
+		t.Errorf("expected nothing logged, got:\n%s", out)
+	}
+}
+
+func TestNewTestServerGenerator(t *testing.T) {
+	ts, _ := NewTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithTestRequestIDGenerator(func() string { return "fixed" }))
+
+	resp := testServerGet(t, ts.URL)
+	ts.Close()
+
+	if got := resp.Header.Get(xRequestIDKey); got != "fixed" {
+		t.Errorf("expected request ID fixed, got %q", got)
+	}
+}
+
+func TestNewTestServerReadWhileServing(t *testing.T) {
+	ts, logs := NewTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithTestLevel(MinimalLevel))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 10; i++ {
+			if resp, err := http.Get(ts.URL); err == nil {
+				resp.Body.Close() // nolint:errcheck
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			ts.Close()
+
+			if n := strings.Count(logs.String(), "(response)"); n != 10 {
+				t.Errorf("expected every entry once the server is closed, got %d", n)
+			}
+
+			return
+		default:
+			_ = logs.String()
+		}
+	}
+}
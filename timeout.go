@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NewTimeout returns a handler that gives the wrapped handler d to respond.
+func NewTimeout(d time.Duration) *Timeout {
+	return &Timeout{Duration: d}
+}
+
+// Timeout is the handler responsible for bounding how long a request may take.
+// The wrapped handler's context is cancelled once Duration has passed, and if
+// it has not started its response by then, Status is sent in its place.
+//
+// Unlike http.TimeoutHandler the response is not buffered, so streamed
+// responses are not held back; a handler that has already started writing
+// when the deadline fires keeps what it sent, and its later writes fail with
+// http.ErrHandlerTimeout.
+type Timeout struct {
+	Duration time.Duration
+	// Status is sent when the handler times out. Defaults to 503 Service
+	// Unavailable; 504 Gateway Timeout suits proxies.
+	Status int
+	// Message is the response body on a timeout. Defaults to the status text.
+	Message string
+}
+
+// Handler implements the middleware interface.
+func (h *Timeout) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), h.Duration)
+		defer cancel()
+
+		tw := &timeoutWriter{
+			ResponseWriter: w,
+			header:         w.Header().Clone(),
+			ctx:            ctx,
+			status:         h.status(),
+			msg:            h.Message,
+		}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicked <- v
+				}
+			}()
+
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case v := <-panicked:
+			panic(v)
+		case <-ctx.Done():
+			tw.timeout()
+		}
+	})
+}
+
+func (h *Timeout) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *Timeout) status() int {
+	if h.Status == 0 {
+		return http.StatusServiceUnavailable
+	}
+
+	return h.Status
+}
+
+// timeoutWriter passes writes through until the request times out. The
+// handler gets its own header map, copied out when the response starts, so
+// that it cannot race with the timeout response. Every write checks ctx, so a
+// handler woken by the deadline cannot slip its response in ahead of the
+// timeout.
+type timeoutWriter struct {
+	http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+	status int
+	msg    string
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(code)
+}
+
+func (w *timeoutWriter) writeHeader(code int) {
+	w.checkDeadline()
+
+	if w.timedOut || w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checkDeadline()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	w.writeHeader(http.StatusOK)
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher when the underlying writer does.
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checkDeadline()
+
+	if w.timedOut {
+		return
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.writeHeader(http.StatusOK)
+		f.Flush()
+	}
+}
+
+// timeout stops further writes once the context is done.
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checkDeadline()
+}
+
+// checkDeadline marks the writer timed out when the context is done, sending
+// the timeout response if the deadline passed before the handler started its
+// own. The caller must hold w.mu.
+func (w *timeoutWriter) checkDeadline() {
+	if w.timedOut || w.ctx.Err() == nil {
+		return
+	}
+
+	w.timedOut = true
+
+	if w.wroteHeader || !errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+
+	msg := w.msg
+	if len(msg) == 0 {
+		msg = http.StatusText(w.status)
+	}
+
+	http.Error(w.ResponseWriter, msg, w.status)
+}
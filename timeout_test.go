@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutFast(t *testing.T) {
+	h := NewTimeout(time.Second).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done")) // nolint:errcheck
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Handler") != "1" {
+		t.Errorf("expected the handler's response, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestTimeoutSlow(t *testing.T) {
+	finished := make(chan error, 1)
+
+	h := &Timeout{Duration: 10 * time.Millisecond, Status: http.StatusGatewayTimeout, Message: "too slow"}
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+
+		w.Header().Set("X-Handler", "1")
+		_, err := w.Write([]byte("late"))
+		finished <- err
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if err := <-finished; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("expected late writes to fail with http.ErrHandlerTimeout, got %v", err)
+	}
+
+	if w.Code != http.StatusGatewayTimeout || strings.TrimSpace(w.Body.String()) != "too slow" {
+		t.Errorf("expected the timeout response, got %d %q", w.Code, w.Body.String())
+	}
+
+	if len(w.Header().Get("X-Handler")) > 0 {
+		t.Error("expected the late handler header to be dropped")
+	}
+}
+
+func TestTimeoutDefaultStatus(t *testing.T) {
+	h := NewTimeout(time.Millisecond).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestTimeoutPartialWrite(t *testing.T) {
+	finished := make(chan struct{})
+
+	h := NewTimeout(10 * time.Millisecond).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(finished)
+
+		w.Write([]byte("partial")) // nolint:errcheck
+		<-r.Context().Done()
+		w.Write([]byte(" more")) // nolint:errcheck
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-finished
+
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("expected the partial response kept, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := NewTimeout(time.Second).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if w.Body.Len() > 0 {
+		t.Errorf("expected no timeout response when the client went away, got %q", w.Body.String())
+	}
+}
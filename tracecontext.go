@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	traceParentKeyName contextKey = "traceparent-key"
+	traceParentHeader  string     = "traceparent"
+)
+
+// TraceParentContextKey is the context key under which the span is stored.
+const TraceParentContextKey = traceParentKeyName
+
+// ErrInvalidTraceParent is returned when a traceparent header is malformed.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceParent identifies a span within a W3C Trace Context trace.
+type TraceParent struct {
+	TraceID string
+	SpanID  string
+	// ParentID is the span ID of the caller, empty for a root span.
+	ParentID string
+	Flags    string
+}
+
+// ParseTraceParent parses a traceparent header value. The span ID of the
+// header becomes the ParentID of the returned span, which is given a new ID.
+func ParseTraceParent(s string) (TraceParent, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 ||
+		!isHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) ||
+		!isHex(parts[1], 32) || parts[1] == strings.Repeat("0", 32) ||
+		!isHex(parts[2], 16) || parts[2] == strings.Repeat("0", 16) ||
+		!isHex(parts[3], 2) {
+		return TraceParent{}, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+
+	return TraceParent{TraceID: parts[1], SpanID: newSpanID(), ParentID: parts[2], Flags: parts[3]}, nil
+}
+
+// NewTraceParent returns a root span in a new trace.
+func NewTraceParent() TraceParent {
+	return TraceParent{TraceID: randomHex(16), SpanID: newSpanID(), Flags: "01"}
+}
+
+// Child returns a new span whose parent is t.
+func (t TraceParent) Child() TraceParent {
+	return TraceParent{TraceID: t.TraceID, SpanID: newSpanID(), ParentID: t.SpanID, Flags: t.Flags}
+}
+
+// String returns the traceparent header value identifying t.
+func (t TraceParent) String() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// WithTraceParent adds the span into the context.
+func WithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKeyName, tp)
+}
+
+// GetTraceParent returns the span from the context and true if it exists.
+func GetTraceParent(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceParentKeyName).(TraceParent)
+
+	return tp, ok
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for _, c := range s {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var traceParentPattern = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+func TestParseTraceParent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tp, err := ParseTraceParent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tp.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tp.ParentID != "00f067aa0ba902b7" || tp.Flags != "01" {
+		t.Errorf("unexpected trace parent %+v", tp)
+	}
+
+	if tp.SpanID == tp.ParentID || !traceParentPattern.MatchString(tp.String()) {
+		t.Errorf("expected a new span, got %+v", tp)
+	}
+}
+
+func TestParseTraceParentMalformed(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceParent(header); !errors.Is(err, ErrInvalidTraceParent) {
+			t.Errorf("expected %q to be rejected, got %v", header, err)
+		}
+	}
+}
+
+func traceParentRequest(t *testing.T, header string) (string, TraceParent) {
+	t.Helper()
+
+	var (
+		id string
+		tp TraceParent
+		ok bool
+	)
+
+	rid := NewRequestIDHandler(nil)
+	rid.TraceParent = true
+
+	h := rid.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = GetRequestID(r.Context())
+		tp, ok = GetTraceParent(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if len(header) > 0 {
+		r.Header.Set(traceParentHeader, header)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !ok {
+		t.Fatal("expected a trace parent in the context")
+	}
+
+	return id, tp
+}
+
+func TestRequestIDTraceParentValid(t *testing.T) {
+	id, tp := traceParentRequest(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	if id != "4bf92f3577b34da6a3ce929d0e0e4736" || tp.TraceID != id || tp.ParentID != "00f067aa0ba902b7" {
+		t.Errorf("expected the incoming trace to be continued, got %q and %+v", id, tp)
+	}
+}
+
+func TestRequestIDTraceParentMalformed(t *testing.T) {
+	id, tp := traceParentRequest(t, "00-not-a-trace-01")
+
+	if tp.TraceID != id || len(tp.ParentID) > 0 || !traceParentPattern.MatchString(tp.String()) {
+		t.Errorf("expected a new root trace, got %q and %+v", id, tp)
+	}
+}
+
+func TestRequestIDTraceParentGenerated(t *testing.T) {
+	id, tp := traceParentRequest(t, "")
+
+	if tp.TraceID != id || len(tp.ParentID) > 0 || tp.Flags != "01" || !traceParentPattern.MatchString(tp.String()) {
+		t.Errorf("expected a new root trace, got %q and %+v", id, tp)
+	}
+}
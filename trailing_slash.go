@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StripSlash returns a handler that removes trailing slashes from request
+// paths before passing them on.
+func StripSlash() *TrailingSlash {
+	return &TrailingSlash{}
+}
+
+// RedirectSlash returns a handler that redirects requests for paths with
+// trailing slashes to the path without them, using code, such as 301 Moved
+// Permanently or 308 Permanent Redirect.
+func RedirectSlash(code int) *TrailingSlash {
+	return &TrailingSlash{Redirect: true, Code: code}
+}
+
+// TrailingSlash is the handler responsible for normalizing trailing slashes
+// in request paths. The root path is left alone.
+type TrailingSlash struct {
+	// Redirect redirects to the canonical path, keeping the query, instead of
+	// rewriting the request.
+	Redirect bool
+	// Code is the redirect status. Defaults to 301 Moved Permanently for GET
+	// and HEAD, and 308 Permanent Redirect, which keeps the method and body,
+	// for other methods.
+	Code int
+}
+
+// Handler implements the middleware interface.
+func (h *TrailingSlash) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if h.Redirect {
+			http.Redirect(w, r, h.location(r), h.code(r))
+
+			return
+		}
+
+		u := *r.URL
+		u.Path = trimSlash(u.Path)
+		u.RawPath = trimSlash(u.RawPath)
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = &u
+
+		next.ServeHTTP(w, r2)
+	})
+}
+
+func (h *TrailingSlash) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h.Handler(next).ServeHTTP(w, r)
+}
+
+func (h *TrailingSlash) code(r *http.Request) int {
+	switch {
+	case h.Code != 0:
+		return h.Code
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return http.StatusMovedPermanently
+	}
+
+	return http.StatusPermanentRedirect
+}
+
+func (h *TrailingSlash) location(r *http.Request) string {
+	// A path such as "//example.com/" must not become a protocol-relative
+	// redirect to another host.
+	loc := "/" + strings.TrimLeft(trimSlash(r.URL.EscapedPath()), "/")
+
+	if len(r.URL.RawQuery) > 0 {
+		loc += "?" + r.URL.RawQuery
+	}
+
+	return loc
+}
+
+// trimSlash removes trailing slashes from p, leaving "/" for the root.
+func trimSlash(p string) string {
+	if len(p) == 0 {
+		return p
+	}
+
+	if t := strings.TrimRight(p, "/"); len(t) > 0 {
+		return t
+	}
+
+	return "/"
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripSlash(t *testing.T) {
+	cases := []struct {
+		target   string
+		expected string
+	}{
+		{target: "/", expected: "/"},
+		{target: "/a", expected: "/a"},
+		{target: "/a/", expected: "/a"},
+		{target: "/a/b//", expected: "/a/b"},
+		{target: "/a/?q=1", expected: "/a?q=1"},
+		{target: "/a%2Fb/", expected: "/a%2Fb"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.target, func(t *testing.T) {
+			var got string
+
+			h := StripSlash().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.RequestURI()
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.target, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected the request passed on, got %d", rec.Code)
+			}
+
+			if got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedirectSlash(t *testing.T) {
+	cases := []struct {
+		name     string
+		code     int
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{name: "root", method: http.MethodGet, target: "/", status: http.StatusOK},
+		{name: "no slash", method: http.MethodGet, target: "/a", status: http.StatusOK},
+		{name: "get", method: http.MethodGet, target: "/a/", status: http.StatusMovedPermanently, location: "/a"},
+		{name: "head", method: http.MethodHead, target: "/a/", status: http.StatusMovedPermanently, location: "/a"},
+		{name: "post", method: http.MethodPost, target: "/a/", status: http.StatusPermanentRedirect, location: "/a"},
+		{name: "query", method: http.MethodGet, target: "/a/?q=1", status: http.StatusMovedPermanently, location: "/a?q=1"},
+		{name: "code", code: http.StatusFound, method: http.MethodPost, target: "/a/", status: http.StatusFound, location: "/a"},
+		{name: "host-like", method: http.MethodGet, target: "//example.com/", status: http.StatusMovedPermanently, location: "/example.com"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			h := RedirectSlash(c.code).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, nil))
+
+			if rec.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, rec.Code)
+			}
+
+			if got := rec.Header().Get("Location"); got != c.location {
+				t.Errorf("expected location %q, got %q", c.location, got)
+			}
+		})
+	}
+}